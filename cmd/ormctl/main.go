@@ -0,0 +1,424 @@
+// Command ormctl is a command-line client for the REST API implemented by
+// each of the sample applications. It can be pointed at whichever application
+// is running to script fixtures and smoke tests.
+//
+// Usage:
+//
+//	ormctl [flags] <customer|product|order> <command> [args]
+//
+// For example:
+//
+//	ormctl customer create -name Billy
+//	ormctl -output json product list -sort -price -filter price.lt=10
+//	ormctl order create -customer 1 -product 2 -product 3 -subtotal 12.50
+//	ormctl order add-product 1 4
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/examples-orms/go/client"
+)
+
+const usage = `usage: ormctl [flags] <resource> <command> [args]
+
+Resources and commands:
+  customer list [-limit N] [-sort FIELDS] [-filter FIELD=VALUE]...
+  customer get ID
+  customer create -name NAME
+  customer update ID -name NAME
+  customer delete ID
+  product list [-limit N] [-sort FIELDS] [-filter FIELD=VALUE]...
+  product get ID
+  product create -name NAME -price PRICE
+  product update ID -name NAME -price PRICE
+  product delete ID
+  order list [-limit N] [-sort FIELDS] [-filter FIELD=VALUE]...
+  order get ID
+  order create -customer ID [-product ID]... -subtotal SUBTOTAL
+  order update ID -customer ID [-product ID]... -subtotal SUBTOTAL
+  order delete ID
+  order add-product ORDER_ID PRODUCT_ID
+
+With -limit, list prints a single page and the cursor of the next page,
+which can be passed to -after. Otherwise it prints every row.
+
+Flags:
+`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "ormctl: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run runs ormctl with the given arguments, writing results to stdout and
+// usage to stderr.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("ormctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	defaultURL := os.Getenv("ORMCTL_URL")
+	if defaultURL == "" {
+		defaultURL = "http://localhost:6543"
+	}
+	baseURL := fs.String("url", defaultURL, "the URL of the application (default from $ORMCTL_URL)")
+	output := fs.String("output", tableOutput, "the output format: table, json or csv")
+	timeout := fs.Duration("timeout", 30*time.Second, "the timeout of each command")
+	retries := fs.Int("retries", 0, "how many times to retry requests that fail with a 503")
+	trailingSlash := fs.Bool("trailing-slash", false, "request paths with a trailing slash, as the Django and Flask applications expect")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	w, err := newWriter(*output, stdout)
+	if err != nil {
+		return err
+	}
+	opts := []client.Option{client.WithRetry(*retries, client.DefaultRetryBackoff)}
+	if *trailingSlash {
+		opts = append(opts, client.WithTrailingSlash())
+	}
+	c, err := client.New(*baseURL, opts...)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	cmd := command{c: c, w: w, stderr: stderr}
+	resource, verb, rest := fs.Arg(0), fs.Arg(1), fs.Args()[2:]
+	switch strings.TrimSuffix(resource, "s") {
+	case "customer":
+		return cmd.customer(ctx, verb, rest)
+	case "product":
+		return cmd.product(ctx, verb, rest)
+	case "order":
+		return cmd.order(ctx, verb, rest)
+	default:
+		return fmt.Errorf("unknown resource %q", resource)
+	}
+}
+
+// command runs a command against an application and writes its result.
+type command struct {
+	c      *client.Client
+	w      writer
+	stderr io.Writer
+}
+
+func (cmd command) customer(ctx context.Context, verb string, args []string) error {
+	fs := cmd.flagSet("customer " + verb)
+	switch verb {
+	case "list":
+		opts := listFlags(fs)
+		if err := parseArgs(fs, args, 0); err != nil {
+			return err
+		}
+		if opts.Limit > 0 {
+			customers, next, err := cmd.c.ListCustomersPage(ctx, opts)
+			if err != nil {
+				return err
+			}
+			return cmd.writePage(customerTable(customers...), next)
+		}
+		customers, err := cmd.c.ListCustomers(ctx, opts)
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(customerTable(customers...))
+	case "get", "delete":
+		if err := parseArgs(fs, args, 1); err != nil {
+			return err
+		}
+		id, err := parseID(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if verb == "delete" {
+			return cmd.c.DeleteCustomer(ctx, id)
+		}
+		customer, err := cmd.c.GetCustomer(ctx, id)
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(customerTable(*customer).single())
+	case "create", "update":
+		name := fs.String("name", "", "the name of the customer")
+		var customer *client.Customer
+		id, err := parseMutation(fs, args, verb)
+		if err != nil {
+			return err
+		}
+		input := client.Customer{Name: *name}
+		if verb == "create" {
+			customer, err = cmd.c.CreateCustomer(ctx, input)
+		} else {
+			customer, err = cmd.c.UpdateCustomer(ctx, id, input)
+		}
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(customerTable(*customer).single())
+	default:
+		return fmt.Errorf("unknown customer command %q", verb)
+	}
+}
+
+func (cmd command) product(ctx context.Context, verb string, args []string) error {
+	fs := cmd.flagSet("product " + verb)
+	switch verb {
+	case "list":
+		opts := listFlags(fs)
+		if err := parseArgs(fs, args, 0); err != nil {
+			return err
+		}
+		if opts.Limit > 0 {
+			products, next, err := cmd.c.ListProductsPage(ctx, opts)
+			if err != nil {
+				return err
+			}
+			return cmd.writePage(productTable(products...), next)
+		}
+		products, err := cmd.c.ListProducts(ctx, opts)
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(productTable(products...))
+	case "get", "delete":
+		if err := parseArgs(fs, args, 1); err != nil {
+			return err
+		}
+		id, err := parseID(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if verb == "delete" {
+			return cmd.c.DeleteProduct(ctx, id)
+		}
+		product, err := cmd.c.GetProduct(ctx, id)
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(productTable(*product).single())
+	case "create", "update":
+		name := fs.String("name", "", "the name of the product")
+		price := fs.Float64("price", 0, "the price of the product")
+		var product *client.Product
+		id, err := parseMutation(fs, args, verb)
+		if err != nil {
+			return err
+		}
+		input := client.Product{Name: *name, Price: *price}
+		if verb == "create" {
+			product, err = cmd.c.CreateProduct(ctx, input)
+		} else {
+			product, err = cmd.c.UpdateProduct(ctx, id, input)
+		}
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(productTable(*product).single())
+	default:
+		return fmt.Errorf("unknown product command %q", verb)
+	}
+}
+
+func (cmd command) order(ctx context.Context, verb string, args []string) error {
+	fs := cmd.flagSet("order " + verb)
+	switch verb {
+	case "list":
+		opts := listFlags(fs)
+		if err := parseArgs(fs, args, 0); err != nil {
+			return err
+		}
+		if opts.Limit > 0 {
+			orders, next, err := cmd.c.ListOrdersPage(ctx, opts)
+			if err != nil {
+				return err
+			}
+			return cmd.writePage(orderTable(orders...), next)
+		}
+		orders, err := cmd.c.ListOrders(ctx, opts)
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(orderTable(orders...))
+	case "get", "delete":
+		if err := parseArgs(fs, args, 1); err != nil {
+			return err
+		}
+		id, err := parseID(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if verb == "delete" {
+			return cmd.c.DeleteOrder(ctx, id)
+		}
+		order, err := cmd.c.GetOrder(ctx, id)
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(orderTable(*order).single())
+	case "create", "update":
+		customerID := fs.Int("customer", 0, "the ID of the customer that placed the order")
+		var productIDs intsFlag
+		fs.Var(&productIDs, "product", "the ID of a product of the order (repeatable)")
+		subtotal := fs.Float64("subtotal", 0, "the subtotal of the order")
+		var order *client.Order
+		id, err := parseMutation(fs, args, verb)
+		if err != nil {
+			return err
+		}
+		input := client.Order{Customer: client.Customer{ID: *customerID}, Subtotal: *subtotal}
+		for _, productID := range productIDs {
+			input.Products = append(input.Products, client.Product{ID: productID})
+		}
+		if verb == "create" {
+			order, err = cmd.c.CreateOrder(ctx, input)
+		} else {
+			order, err = cmd.c.UpdateOrder(ctx, id, input)
+		}
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(orderTable(*order).single())
+	case "add-product":
+		if err := parseArgs(fs, args, 2); err != nil {
+			return err
+		}
+		orderID, err := parseID(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		productID, err := parseID(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		order, err := cmd.c.AddProductToOrder(ctx, orderID, productID)
+		if err != nil {
+			return err
+		}
+		return cmd.w.write(orderTable(*order).single())
+	default:
+		return fmt.Errorf("unknown order command %q", verb)
+	}
+}
+
+func (cmd command) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(cmd.stderr)
+	return fs
+}
+
+// writePage writes a page of rows, followed by the cursor of the next page
+// on stderr so that the output stays machine-readable.
+func (cmd command) writePage(t table, next string) error {
+	if err := cmd.w.write(t); err != nil {
+		return err
+	}
+	if next != "" {
+		fmt.Fprintf(cmd.stderr, "next page: -after %s\n", next)
+	}
+	return nil
+}
+
+// listFlags defines the flags of list commands on fs.
+func listFlags(fs *flag.FlagSet) *client.ListOptions {
+	opts := &client.ListOptions{Filters: url.Values{}}
+	fs.IntVar(&opts.Limit, "limit", 0, "print a single page of at most this many rows")
+	fs.StringVar(&opts.After, "after", "", "the cursor of the page to print")
+	fs.StringVar(&opts.Sort, "sort", "", "the fields to sort by, each prefixed with '-' for descending order")
+	fs.Var(filterFlag(opts.Filters), "filter", "a filter of the form field=value or field.op=value (repeatable)")
+	return opts
+}
+
+// parseArgs parses the flags and arguments of a command, which takes n
+// positional arguments. Flags may precede or follow the arguments.
+func parseArgs(fs *flag.FlagSet, args []string, n int) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != n {
+		return fmt.Errorf("%s: expected %d arguments, found %d", fs.Name(), n, len(positional))
+	}
+	return fs.Parse(positional)
+}
+
+// parseMutation parses the flags and arguments of a create or update command,
+// and returns the ID of the row to update.
+func parseMutation(fs *flag.FlagSet, args []string, verb string) (int, error) {
+	if verb == "create" {
+		return 0, parseArgs(fs, args, 0)
+	}
+	if err := parseArgs(fs, args, 1); err != nil {
+		return 0, err
+	}
+	return parseID(fs.Arg(0))
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q", s)
+	}
+	return id, nil
+}
+
+// intsFlag is a repeatable flag of integers.
+type intsFlag []int
+
+func (f *intsFlag) String() string {
+	return fmt.Sprint([]int(*f))
+}
+
+func (f *intsFlag) Set(s string) error {
+	i, err := parseID(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, i)
+	return nil
+}
+
+// filterFlag is a repeatable flag of field=value filters.
+type filterFlag url.Values
+
+func (f filterFlag) String() string {
+	return url.Values(f).Encode()
+}
+
+func (f filterFlag) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("filter %q must be of the form field=value", s)
+	}
+	url.Values(f).Add(s[:i], s[i+1:])
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), bytes.TrimSpace(body)))
+		switch r.URL.Path {
+		case "/product":
+			fmt.Fprint(w, `[{"id": 1, "name": "Ice Cream", "price": "123.40"}, {"id": 2, "name": "Cone, Waffle", "price": "2"}]`)
+		case "/order/1/product":
+			fmt.Fprint(w, `{"id": 1, "subtotal": "10", "customer": {"id": 3, "name": "Billy"}, "products": [{"id": 1}, {"id": 2}]}`)
+		default:
+			fmt.Fprint(w, `{"id": 3, "name": "Billy"}`)
+		}
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		args     []string
+		request  string
+		expected string
+	}{
+		{
+			args:    []string{"product", "list", "-sort", "-price", "-filter", "name.prefix=Ice"},
+			request: "GET /product?name.prefix=Ice&sort=-price ",
+			expected: "ID  NAME          PRICE\n" +
+				"1   Ice Cream     123.40\n" +
+				"2   Cone, Waffle  2.00\n",
+		},
+		{
+			args:     []string{"-output", "csv", "product", "list"},
+			request:  "GET /product ",
+			expected: "id,name,price\n1,Ice Cream,123.40\n2,\"Cone, Waffle\",2.00\n",
+		},
+		{
+			args:     []string{"-output", "json", "customer", "update", "3", "-name", "Billy"},
+			request:  `PUT /customer/3 {"name":"Billy"}`,
+			expected: "{\n  \"id\": 3,\n  \"name\": \"Billy\"\n}\n",
+		},
+		{
+			args:     []string{"-output", "csv", "order", "add-product", "1", "2"},
+			request:  "POST /order/1/product?productID=2 ",
+			expected: "id,customer_id,customer_name,subtotal,product_ids\n1,3,Billy,10.00,1 2\n",
+		},
+		{
+			args:    []string{"order", "create", "-customer", "3", "-product", "1", "-product", "2", "-subtotal", "10"},
+			request: `POST /order {"subtotal":"10","customer":{"id":3,"name":""},"products":[{"id":1,"name":"","price":"0"},{"id":2,"name":"","price":"0"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		requests = nil
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"-url", srv.URL}, tc.args...)
+		if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+			t.Fatalf("%v: %v\n%s", tc.args, err, stderr.String())
+		}
+		if len(requests) != 1 || requests[0] != tc.request {
+			t.Errorf("%v: expected request %q, found %q", tc.args, tc.request, requests)
+		}
+		if tc.expected != "" && stdout.String() != tc.expected {
+			t.Errorf("%v: expected output:\n%s\nfound:\n%s", tc.args, tc.expected, stdout.String())
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"title": "Not Found", "status": 404})
+	}))
+	defer srv.Close()
+
+	for _, args := range [][]string{
+		{"customer", "get", "9"},
+		{"customer", "get", "abc"},
+		{"customer", "get"},
+		{"widget", "list"},
+		{"-output", "xml", "customer", "list"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if err := run(context.Background(), append([]string{"-url", srv.URL}, args...), &stdout, &stderr); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/examples-orms/go/client"
+)
+
+// The output formats.
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+	csvOutput   = "csv"
+)
+
+// table is the result of a command: rows of columns for the table and CSV
+// formats, and the values the rows were made from for the JSON format.
+type table struct {
+	header []string
+	rows   [][]string
+	values interface{}
+}
+
+// single makes the JSON format of a table of a single row an object rather
+// than an array.
+func (t table) single() table {
+	v := reflect.ValueOf(t.values)
+	if v.Kind() == reflect.Slice && v.Len() == 1 {
+		t.values = v.Index(0).Interface()
+	}
+	return t
+}
+
+func customerTable(customers ...client.Customer) table {
+	t := table{header: []string{"id", "name"}, values: customers}
+	for _, c := range customers {
+		t.rows = append(t.rows, []string{strconv.Itoa(c.ID), c.Name})
+	}
+	return t
+}
+
+func productTable(products ...client.Product) table {
+	t := table{header: []string{"id", "name", "price"}, values: products}
+	for _, p := range products {
+		t.rows = append(t.rows, []string{strconv.Itoa(p.ID), p.Name, formatDecimal(p.Price)})
+	}
+	return t
+}
+
+func orderTable(orders ...client.Order) table {
+	t := table{header: []string{"id", "customer_id", "customer_name", "subtotal", "product_ids"}, values: orders}
+	for _, o := range orders {
+		productIDs := make([]string, len(o.Products))
+		for i, p := range o.Products {
+			productIDs[i] = strconv.Itoa(p.ID)
+		}
+		t.rows = append(t.rows, []string{
+			strconv.Itoa(o.ID),
+			strconv.Itoa(o.Customer.ID),
+			o.Customer.Name,
+			formatDecimal(o.Subtotal),
+			strings.Join(productIDs, " "),
+		})
+	}
+	return t
+}
+
+func formatDecimal(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// writer writes tables in an output format.
+type writer interface {
+	write(table) error
+}
+
+func newWriter(format string, w io.Writer) (writer, error) {
+	switch format {
+	case tableOutput:
+		return tableWriter{w}, nil
+	case jsonOutput:
+		return jsonWriter{w}, nil
+	case csvOutput:
+		return csvWriter{w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+type tableWriter struct {
+	w io.Writer
+}
+
+func (tw tableWriter) write(t table) error {
+	w := tabwriter.NewWriter(tw.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(strings.Join(t.header, "\t")))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+type jsonWriter struct {
+	w io.Writer
+}
+
+func (jw jsonWriter) write(t table) error {
+	enc := json.NewEncoder(jw.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t.values)
+}
+
+type csvWriter struct {
+	w io.Writer
+}
+
+func (cw csvWriter) write(t table) error {
+	w := csv.NewWriter(cw.w)
+	if err := w.Write(t.header); err != nil {
+		return err
+	}
+	if err := w.WriteAll(t.rows); err != nil {
+		return err
+	}
+	return w.Error()
+}
@@ -4,11 +4,18 @@ go 1.13
 
 require (
 	github.com/cockroachdb/cockroach-go/v2 v2.2.20
+	github.com/getkin/kin-openapi v0.94.0
 	github.com/go-pg/pg/v10 v10.9.0
-	github.com/julienschmidt/httprouter v1.1.0
+	github.com/jackc/pgconn v1.12.1
+	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.6
 	github.com/pkg/errors v0.9.1
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	github.com/prometheus/client_golang v1.12.2
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.7.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
 	gorm.io/driver/postgres v1.3.5
 	gorm.io/gorm v1.23.5
 )
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// SQLSTATE codes that are mapped to specific HTTP status codes. See
+// https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	CodeUniqueViolation      = "23505"
+	CodeForeignKeyViolation  = "23503"
+	CodeNotNullViolation     = "23502"
+	CodeInvalidTextRepr      = "22P02"
+	CodeSerializationFailure = "40001"
+	CodeQueryCanceled        = "57014"
+
+	// codeRetriableErrorLegacy is the retryable error code used by CockroachDB
+	// versions that predate its use of CodeSerializationFailure.
+	codeRetriableErrorLegacy = "CR000"
+)
+
+// StatusClientClosedRequest is the non-standard status code, borrowed from
+// nginx, of requests that the client abandoned before they were served. The
+// client does not see the response, but it is logged and counted.
+const StatusClientClosedRequest = 499
+
+// sqlStateError is implemented by errors that expose their SQLSTATE code
+// directly, such as *pgconn.PgError (used by gorm) and *pq.Error.
+type sqlStateError interface {
+	SQLState() string
+}
+
+// fieldError is implemented by go-pg's pg.Error, which exposes the SQLSTATE
+// code as the 'C' field of the server's ErrorResponse.
+type fieldError interface {
+	Field(field byte) string
+}
+
+// SQLState returns the SQLSTATE code carried by err or by any error it wraps,
+// or the empty string if there is none.
+func SQLState(err error) string {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	var fieldErr fieldError
+	if errors.As(err, &fieldErr) {
+		return fieldErr.Field('C')
+	}
+	return ""
+}
+
+// StatusCode classifies err into the HTTP status code that best describes it
+// to a client. Malformed input, such as an invalid JSON body or ID, maps to
+// 400, and errors that cannot be classified map to 500. Requests that
+// run past their deadline map to 504, like statements that time out, and
+// requests that the client abandoned map to StatusClientClosedRequest. Rows
+// that do not exist map to 404, and references to them to 422. Retryable
+// errors map to 503.
+func StatusCode(err error) int {
+	if errors.Is(err, ErrNotFound) {
+		return http.StatusNotFound
+	}
+	var refErr *ReferenceError
+	if errors.As(err, &refErr) {
+		return http.StatusUnprocessableEntity
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var numErr *strconv.NumError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &numErr) {
+		return http.StatusBadRequest
+	}
+	var retriesErr *RetriesExhaustedError
+	if errors.As(err, &retriesErr) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return StatusClientClosedRequest
+	}
+
+	if IsRetryable(err) {
+		return http.StatusServiceUnavailable
+	}
+	switch code := SQLState(err); code {
+	case CodeUniqueViolation:
+		return http.StatusConflict
+	case CodeForeignKeyViolation:
+		return http.StatusUnprocessableEntity
+	case CodeNotNullViolation, CodeInvalidTextRepr:
+		return http.StatusBadRequest
+	case CodeQueryCanceled:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// IsRetryable reports whether err is a retryable transaction error, or the
+// error of a transaction that exhausted its retries after such errors. The
+// client can retry the request later.
+func IsRetryable(err error) bool {
+	var retriesErr *RetriesExhaustedError
+	if errors.As(err, &retriesErr) {
+		return true
+	}
+	code := SQLState(err)
+	return code == CodeSerializationFailure || code == codeRetriableErrorLegacy
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+// gopgError mimics go-pg's pg.Error, which exposes fields of the server's
+// ErrorResponse by their protocol identifier.
+type gopgError map[byte]string
+
+func (e gopgError) Error() string           { return e['M'] }
+func (e gopgError) Field(field byte) string { return e[field] }
+
+func TestStatusCode(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{fmt.Errorf("boom"), http.StatusInternalServerError},
+		{&pgconn.PgError{Code: CodeUniqueViolation}, http.StatusConflict},
+		{&pgconn.PgError{Code: CodeForeignKeyViolation}, http.StatusUnprocessableEntity},
+		{&pgconn.PgError{Code: CodeNotNullViolation}, http.StatusBadRequest},
+		{&pgconn.PgError{Code: CodeInvalidTextRepr}, http.StatusBadRequest},
+		{&pgconn.PgError{Code: CodeSerializationFailure}, http.StatusServiceUnavailable},
+		{&pgconn.PgError{Code: CodeQueryCanceled}, http.StatusGatewayTimeout},
+		{&pgconn.PgError{Code: "XX000"}, http.StatusInternalServerError},
+		{gopgError{'C': CodeUniqueViolation}, http.StatusConflict},
+		{gopgError{'C': CodeQueryCanceled}, http.StatusGatewayTimeout},
+		{func() error { _, err := strconv.Atoi("abc"); return err }(), http.StatusBadRequest},
+		{fmt.Errorf("querying: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{fmt.Errorf("querying: %w", context.Canceled), StatusClientClosedRequest},
+		{&RetriesExhaustedError{Err: &pgconn.PgError{Code: CodeSerializationFailure}}, http.StatusServiceUnavailable},
+		{fmt.Errorf("wrapped: %w", gopgError{'C': CodeForeignKeyViolation}), http.StatusUnprocessableEntity},
+		{fmt.Errorf("customer 1 %w", ErrNotFound), http.StatusNotFound},
+		{&ReferenceError{Entity: "product", ID: 1}, http.StatusUnprocessableEntity},
+	}
+	for _, tc := range testCases {
+		if actual := StatusCode(tc.err); actual != tc.expected {
+			t.Errorf("StatusCode(%#v) = %d, expected %d", tc.err, actual, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// readinessCheckTimeout bounds the duration of each readiness check.
+const readinessCheckTimeout = 2 * time.Second
+
+// ReadinessCheck is a named check of a dependency that a server needs in
+// order to serve requests.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// healthStatus is the body of health check responses.
+type healthStatus struct {
+	Status string            `json:"status"`
+	App    string            `json:"app"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz returns the handler of a liveness probe for the given app, which
+// reports that the process is serving requests without checking anything
+// else.
+func Healthz(app string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthStatus(w, http.StatusOK, healthStatus{Status: "ok", App: app})
+	})
+}
+
+// Readyz returns the handler of a readiness probe for the given app, which
+// runs the checks and only responds with a 200 if all of them pass. The
+// result of each check is reported in the response.
+func Readyz(app string, checks ...ReadinessCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := healthStatus{Status: "ready", App: app, Checks: make(map[string]string, len(checks))}
+		code := http.StatusOK
+		for _, c := range checks {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			err := c.Check(ctx)
+			cancel()
+			if err != nil {
+				status.Status = "unavailable"
+				status.Checks[c.Name] = err.Error()
+				code = http.StatusServiceUnavailable
+			} else {
+				status.Checks[c.Name] = "ok"
+			}
+		}
+		writeHealthStatus(w, code, status)
+	})
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, status healthStatus) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("failed to encode health status: %v", err)
+	}
+}
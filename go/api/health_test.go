@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	ok := ReadinessCheck{Name: "database", Check: func(context.Context) error { return nil }}
+	failing := ReadinessCheck{Name: "schema", Check: func(context.Context) error { return errors.New("missing") }}
+
+	testCases := []struct {
+		checks   []ReadinessCheck
+		code     int
+		expected healthStatus
+	}{
+		{
+			checks:   []ReadinessCheck{ok},
+			code:     http.StatusOK,
+			expected: healthStatus{Status: "ready", App: "go/test", Checks: map[string]string{"database": "ok"}},
+		},
+		{
+			checks: []ReadinessCheck{ok, failing},
+			code:   http.StatusServiceUnavailable,
+			expected: healthStatus{
+				Status: "unavailable", App: "go/test", Checks: map[string]string{"database": "ok", "schema": "missing"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		Readyz("go/test", tc.checks...).ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		if w.Code != tc.code {
+			t.Errorf("expected status %d, found %d", tc.code, w.Code)
+		}
+		var found healthStatus
+		if err := json.NewDecoder(w.Body).Decode(&found); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tc.expected, found) {
+			t.Errorf("expected %+v, found %+v", tc.expected, found)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Log levels.
+const (
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+)
+
+// Logger writes structured log entries to an io.Writer as JSON, one entry per
+// line. It logs each request served and the SQL statements issued by the
+// ORMs, tagged with the ID of the request that issued them.
+type Logger struct {
+	// SlowStatementThreshold is the duration above which statements are
+	// logged as slow. Zero disables slow statement logging.
+	SlowStatementThreshold time.Duration
+	// LogStatements enables logging every statement, not only the slow and
+	// failed ones.
+	LogStatements bool
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogger creates a Logger that writes to w.
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+// logEntry is the part of a log entry that all entries share.
+type logEntry struct {
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"`
+	Msg       string    `json:"msg"`
+	RequestID string    `json:"request_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func newLogEntry(ctx context.Context, level, msg string, err error) logEntry {
+	e := logEntry{
+		Time:      time.Now().UTC(),
+		Level:     level,
+		Msg:       msg,
+		RequestID: RequestIDFromContext(ctx),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+type requestLogEntry struct {
+	logEntry
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+type statementLogEntry struct {
+	logEntry
+	ORM          string  `json:"orm"`
+	Statement    string  `json:"statement"`
+	RowsAffected int64   `json:"rows_affected"`
+	DurationMS   float64 `json:"duration_ms"`
+}
+
+func (l *Logger) write(entry interface{}) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(b)
+}
+
+// Infof logs an informational message, tagged with the ID of the request that
+// ctx belongs to.
+func (l *Logger) Infof(ctx context.Context, format string, args ...interface{}) {
+	l.write(newLogEntry(ctx, levelInfo, fmt.Sprintf(format, args...), nil))
+}
+
+// Warnf logs a warning, tagged with the ID of the request that ctx belongs
+// to.
+func (l *Logger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	l.write(newLogEntry(ctx, levelWarn, fmt.Sprintf(format, args...), nil))
+}
+
+// Errorf logs an error message, tagged with the ID of the request that ctx
+// belongs to.
+func (l *Logger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	l.write(newLogEntry(ctx, levelError, fmt.Sprintf(format, args...), nil))
+}
+
+// logError logs err, tagged with the ID of the request that ctx belongs to.
+func (l *Logger) logError(ctx context.Context, msg string, err error) {
+	l.write(newLogEntry(ctx, levelError, msg, err))
+}
+
+// LogStatement logs a SQL statement issued by the given ORM that took
+// duration to run, if it failed, was slow or l.LogStatements is set.
+func (l *Logger) LogStatement(
+	ctx context.Context, orm, statement string, duration time.Duration, rowsAffected int64, err error,
+) {
+	var level, msg string
+	switch {
+	case err != nil:
+		level, msg = levelError, "statement failed"
+	case l.SlowStatementThreshold > 0 && duration >= l.SlowStatementThreshold:
+		level, msg = levelWarn, "slow statement"
+	case l.LogStatements:
+		level, msg = levelInfo, "statement"
+	default:
+		return
+	}
+	l.write(statementLogEntry{
+		logEntry:     newLogEntry(ctx, level, msg, err),
+		ORM:          orm,
+		Statement:    statement,
+		RowsAffected: rowsAffected,
+		DurationMS:   milliseconds(duration),
+	})
+}
+
+// LogRequests returns a handler that assigns each request to h an ID, or
+// propagates the one in its X-Request-ID header, and logs the request once it
+// has been served. The ID is available to h through RequestIDFromContext.
+func (l *Logger) LogRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := context.WithValue(r.Context(), requestIDKey{}, RequestID(w, r))
+		ctx = context.WithValue(ctx, loggerKey{}, l)
+		r = r.WithContext(ctx)
+		rec := newResponseRecorder(w)
+		defer func() {
+			l.write(requestLogEntry{
+				logEntry:  newLogEntry(ctx, levelInfo, "request", nil),
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Status:    rec.Status(),
+				Bytes:     rec.bytes,
+				LatencyMS: milliseconds(time.Since(start)),
+			})
+		}()
+		h.ServeHTTP(rec, r)
+	})
+}
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// RequestIDFromContext returns the ID of the request that ctx belongs to, or
+// the empty string if ctx does not belong to a request.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// loggerFromContext returns the Logger of the request that ctx belongs to, if
+// any.
+func loggerFromContext(ctx context.Context) *Logger {
+	l, _ := ctx.Value(loggerKey{}).(*Logger)
+	return l
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
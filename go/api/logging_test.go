@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLogRequests(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf)
+	l.LogStatements = true
+	h := l.LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.LogStatement(r.Context(), "test", "SELECT 1", time.Millisecond, 1, nil)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{header: "client-id-1", expected: "client-id-1"},
+		{header: "not valid", expected: ""},
+		{header: "", expected: ""},
+	}
+	for _, tc := range testCases {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/customer?limit=1", nil)
+		if tc.header != "" {
+			req.Header.Set(RequestIDHeader, tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		if id == "" || (tc.expected != "" && id != tc.expected) || (tc.expected == "" && id == tc.header) {
+			t.Errorf("%q: unexpected request ID %q", tc.header, id)
+		}
+
+		dec := json.NewDecoder(&buf)
+		var stmt statementLogEntry
+		if err := dec.Decode(&stmt); err != nil {
+			t.Fatal(err)
+		}
+		if stmt.RequestID != id || stmt.Statement != "SELECT 1" {
+			t.Errorf("%q: unexpected statement entry %+v", tc.header, stmt)
+		}
+		var entry requestLogEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.RequestID != id || entry.Method != "GET" || entry.Path != "/customer?limit=1" || entry.Status != http.StatusTeapot {
+			t.Errorf("%q: unexpected request entry %+v", tc.header, entry)
+		}
+	}
+}
+
+func TestLogStatement(t *testing.T) {
+	testCases := []struct {
+		logAll   bool
+		duration time.Duration
+		err      error
+		expected string
+	}{
+		{duration: time.Millisecond, expected: ""},
+		{duration: time.Millisecond, logAll: true, expected: "statement"},
+		{duration: time.Second, expected: "slow statement"},
+		{duration: time.Millisecond, err: errors.New("boom"), expected: "statement failed"},
+	}
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		l := NewLogger(&buf)
+		l.SlowStatementThreshold = 100 * time.Millisecond
+		l.LogStatements = tc.logAll
+		l.LogStatement(context.Background(), "test", "SELECT 1", tc.duration, 0, tc.err)
+
+		var found string
+		if buf.Len() > 0 {
+			var e statementLogEntry
+			if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			found = e.Msg
+		}
+		if found != tc.expected {
+			t.Errorf("%+v: expected %q, found %q", tc, tc.expected, found)
+		}
+	}
+}
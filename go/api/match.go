@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Match reports whether row satisfies the filters of the query and, if the
+// query continues from a cursor, sorts after it. It evaluates in Go what Where
+// expresses in SQL, for Stores that do not keep their rows in a database.
+func (lq *ListQuery) Match(row Row) bool {
+	for _, f := range lq.filters {
+		v := row[f.field.Name]
+		if f.op == prefixOp {
+			if !strings.HasPrefix(stringValue(v), f.value.(string)) {
+				return false
+			}
+			continue
+		}
+		c := compareValues(f.field.Type, v, f.value)
+		var ok bool
+		switch f.op {
+		case "eq":
+			ok = c == 0
+		case "lt":
+			ok = c < 0
+		case "lte":
+			ok = c <= 0
+		case "gt":
+			ok = c > 0
+		case "gte":
+			ok = c >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	if lq.keyset != nil {
+		for i, key := range lq.sort {
+			if c := key.compare(row[key.field.Name], lq.keyset[i]); c != 0 {
+				return c > 0
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Compare compares rows a and b by the sort order of the query. The result is
+// negative if a sorts before b, positive if it sorts after b, and 0 if they
+// compare equal on all sort keys. Like Match, it is meant for Stores that sort
+// their rows themselves.
+func (lq *ListQuery) Compare(a, b Row) int {
+	for _, key := range lq.sort {
+		if c := key.compare(a[key.field.Name], b[key.field.Name]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (key sortKey) compare(a, b interface{}) int {
+	c := compareValues(key.field.Type, a, b)
+	if key.desc {
+		return -c
+	}
+	return c
+}
+
+// compareValues compares two values of a field of type t, which may either
+// come from a Row or have been parsed from a query parameter or cursor.
+func compareValues(t FieldType, a, b interface{}) int {
+	switch t {
+	case IntField:
+		x, y := intValue(a), intValue(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case DecimalField:
+		x, y := decimalValue(a), decimalValue(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	default:
+		return strings.Compare(stringValue(a), stringValue(b))
+	}
+}
+
+func intValue(v interface{}) int64 {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case nil:
+		return 0
+	default:
+		panic(fmt.Sprintf("unexpected integer value %#v", v))
+	}
+}
+
+func decimalValue(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case string:
+		// Decimal strings have been validated when they were parsed.
+		d, _ := strconv.ParseFloat(v, 64)
+		return d
+	case nil:
+		return 0
+	default:
+		panic(fmt.Sprintf("unexpected decimal value %#v", v))
+	}
+}
+
+func stringValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case *string:
+		if v != nil {
+			return *v
+		}
+		return ""
+	case nil:
+		return ""
+	default:
+		panic(fmt.Sprintf("unexpected string value %#v", v))
+	}
+}
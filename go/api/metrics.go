@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus metrics of a server.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	// TxnRetries counts the retries of transactions that hit a retryable
+	// error.
+	TxnRetries prometheus.Counter
+	// TxnRetriesExhausted counts the transactions that were given up on after
+	// exhausting their retries.
+	TxnRetriesExhausted prometheus.Counter
+	// PanicsRecovered counts the panics of handlers that were recovered from;
+	// see Recover.
+	PanicsRecovered prometheus.Counter
+}
+
+// NewMetrics creates the metrics of a server, along with the standard Go
+// runtime and process metrics.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests served, by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		TxnRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "db_txn_retries_total",
+			Help: "Number of times a transaction was retried after a retryable error.",
+		}),
+		TxnRetriesExhausted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "db_txn_retries_exhausted_total",
+			Help: "Number of transactions that failed after exhausting their retries.",
+		}),
+		PanicsRecovered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_panics_recovered_total",
+			Help: "Number of panics of HTTP handlers that were recovered from.",
+		}),
+	}
+	m.MustRegister(
+		m.requests,
+		m.duration,
+		m.TxnRetries,
+		m.TxnRetriesExhausted,
+		m.PanicsRecovered,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// MustRegister registers additional collectors, such as those exposing the
+// statistics of a connection pool. It panics if a collector cannot be
+// registered.
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
+
+// Handler returns the handler that serves the metrics to Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Instrument returns a handler that runs h and records the count, status and
+// latency of each request under the pattern of the route that served it; see
+// handleRoute.
+func (m *Metrics) Instrument(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		r, route := withMatchedRoute(r)
+		defer func() {
+			m.duration.WithLabelValues(route.String(), r.Method).Observe(time.Since(start).Seconds())
+			m.requests.WithLabelValues(route.String(), r.Method, strconv.Itoa(rec.Status())).Inc()
+		}()
+		h.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsInstrument(t *testing.T) {
+	router := httprouter.New()
+	handleRoute(router, http.MethodGet, "/order/:orderID", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handleRoute(router, http.MethodPost, "/order/:orderID/product", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+
+	m := NewMetrics()
+	h := m.Instrument(router)
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/order/1"},
+		// IDs that look like segments of the pattern do not confuse the
+		// route label.
+		{"GET", "/order/order"},
+		{"POST", "/order/1/product"},
+		{"GET", "/missing"},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	expected := `
+# HELP http_requests_total Number of HTTP requests served, by route, method and status code.
+# TYPE http_requests_total counter
+http_requests_total{code="200",method="POST",route="/order/:orderID/product"} 1
+http_requests_total{code="404",method="GET",route="/order/:orderID"} 2
+http_requests_total{code="404",method="GET",route="unmatched"} 1
+`
+	if err := testutil.CollectAndCompare(m.requests, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(m.duration); n != 3 {
+		t.Errorf("expected 3 latency histograms, found %d", n)
+	}
+}
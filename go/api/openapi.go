@@ -0,0 +1,334 @@
+package api
+
+import (
+	"net/http"
+)
+
+// OpenAPIPath is the path at which the Go servers serve OpenAPISpec.
+const OpenAPIPath = "/openapi.json"
+
+// OpenAPISpec returns the OpenAPI 3 document that describes the REST API
+// that every sample application implements, in JSON.
+func OpenAPISpec() []byte {
+	return []byte(openAPISpec)
+}
+
+// OpenAPIHandler returns a handler that serves OpenAPISpec.
+func OpenAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(OpenAPISpec())
+	})
+}
+
+// openAPISpec is kept in a Go string rather than in a separate file so that
+// the servers do not depend on their working directory to serve it.
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "CockroachDB ORM examples sample application",
+    "description": "The REST API that every sample application implements on top of its ORM. Decimal values are encoded as JSON strings so that they round-trip without loss of precision. The Go applications additionally support the pagination, filtering and sorting parameters, and report errors as RFC 7807 problem details.",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/customer": {
+      "get": {
+        "operationId": "listCustomers",
+        "summary": "List customers",
+        "description": "Customers can be filtered and sorted by id and name.",
+        "parameters": [
+          {"$ref": "#/components/parameters/limit"},
+          {"$ref": "#/components/parameters/after"},
+          {"$ref": "#/components/parameters/sort"}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of customers.",
+            "headers": {
+              "X-Next-Cursor": {"$ref": "#/components/headers/NextCursor"},
+              "Link": {"$ref": "#/components/headers/Link"}
+            },
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Customer"}}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "post": {
+        "operationId": "createCustomer",
+        "summary": "Create a customer",
+        "requestBody": {"$ref": "#/components/requestBodies/Customer"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Customer"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/customer/{customerID}": {
+      "parameters": [{"$ref": "#/components/parameters/customerID"}],
+      "get": {
+        "operationId": "getCustomer",
+        "summary": "Get a customer",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Customer"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "put": {
+        "operationId": "updateCustomer",
+        "summary": "Update a customer",
+        "requestBody": {"$ref": "#/components/requestBodies/Customer"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Customer"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteCustomer",
+        "summary": "Delete a customer",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Deleted"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/product": {
+      "get": {
+        "operationId": "listProducts",
+        "summary": "List products",
+        "description": "Products can be filtered and sorted by id, name and price.",
+        "parameters": [
+          {"$ref": "#/components/parameters/limit"},
+          {"$ref": "#/components/parameters/after"},
+          {"$ref": "#/components/parameters/sort"}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of products.",
+            "headers": {
+              "X-Next-Cursor": {"$ref": "#/components/headers/NextCursor"},
+              "Link": {"$ref": "#/components/headers/Link"}
+            },
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Product"}}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "post": {
+        "operationId": "createProduct",
+        "summary": "Create a product",
+        "requestBody": {"$ref": "#/components/requestBodies/Product"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Product"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/product/{productID}": {
+      "parameters": [{"$ref": "#/components/parameters/productID"}],
+      "get": {
+        "operationId": "getProduct",
+        "summary": "Get a product",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Product"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "put": {
+        "operationId": "updateProduct",
+        "summary": "Update a product",
+        "requestBody": {"$ref": "#/components/requestBodies/Product"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Product"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteProduct",
+        "summary": "Delete a product",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Deleted"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/order": {
+      "get": {
+        "operationId": "listOrders",
+        "summary": "List orders",
+        "description": "Orders can be filtered and sorted by id, customer_id and subtotal.",
+        "parameters": [
+          {"$ref": "#/components/parameters/limit"},
+          {"$ref": "#/components/parameters/after"},
+          {"$ref": "#/components/parameters/sort"}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of orders.",
+            "headers": {
+              "X-Next-Cursor": {"$ref": "#/components/headers/NextCursor"},
+              "Link": {"$ref": "#/components/headers/Link"}
+            },
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Order"}}}}
+          },
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "post": {
+        "operationId": "createOrder",
+        "summary": "Create an order",
+        "description": "The order belongs to the customer with the given ID and holds the products with the given IDs, all of which must exist.",
+        "requestBody": {"$ref": "#/components/requestBodies/Order"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Order"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/order/{orderID}": {
+      "parameters": [{"$ref": "#/components/parameters/orderID"}],
+      "get": {
+        "operationId": "getOrder",
+        "summary": "Get an order",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Order"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "put": {
+        "operationId": "updateOrder",
+        "summary": "Update an order",
+        "requestBody": {"$ref": "#/components/requestBodies/Order"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Order"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteOrder",
+        "summary": "Delete an order",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Deleted"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/order/{orderID}/product": {
+      "parameters": [{"$ref": "#/components/parameters/orderID"}],
+      "post": {
+        "operationId": "addProductToOrder",
+        "summary": "Add a product to an order",
+        "parameters": [
+          {"name": "productID", "in": "query", "required": true, "description": "The ID of the product to add.", "schema": {"type": "integer"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Order"},
+          "default": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Decimal": {
+        "type": "string",
+        "description": "A decimal number, encoded as a string.",
+        "pattern": "^-?[0-9]+(\\.[0-9]+)?([eE][-+]?[0-9]+)?$"
+      },
+      "Customer": {
+        "type": "object",
+        "required": ["id", "name"],
+        "properties": {
+          "id": {"type": "integer", "readOnly": true},
+          "name": {"type": "string"}
+        }
+      },
+      "Product": {
+        "type": "object",
+        "required": ["id", "name", "price"],
+        "properties": {
+          "id": {"type": "integer", "readOnly": true},
+          "name": {"type": "string", "description": "The name of the product, which is unique."},
+          "price": {"$ref": "#/components/schemas/Decimal"}
+        }
+      },
+      "Order": {
+        "type": "object",
+        "description": "An order, along with the customer that placed it and its products. Requests only need to give the IDs of the customer and products.",
+        "required": ["id", "subtotal", "customer", "products"],
+        "properties": {
+          "id": {"type": "integer", "readOnly": true},
+          "subtotal": {"$ref": "#/components/schemas/Decimal"},
+          "customer": {"$ref": "#/components/schemas/Customer"},
+          "products": {
+            "type": "array",
+            "description": "The products of the order, sorted by ID.",
+            "items": {"$ref": "#/components/schemas/Product"}
+          }
+        }
+      },
+      "Problem": {
+        "type": "object",
+        "description": "An RFC 7807 problem details object.",
+        "required": ["type", "title", "status"],
+        "properties": {
+          "type": {"type": "string"},
+          "title": {"type": "string"},
+          "status": {"type": "integer"},
+          "detail": {"type": "string"},
+          "sqlstate": {"type": "string", "description": "The SQLSTATE code of the database error that caused the problem."},
+          "request_id": {"type": "string", "description": "The ID of the request, which is also returned in the X-Request-ID header."}
+        }
+      }
+    },
+    "parameters": {
+      "customerID": {"name": "customerID", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "productID": {"name": "productID", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "orderID": {"name": "orderID", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "limit": {
+        "name": "limit", "in": "query",
+        "description": "The maximum number of rows in the page. Every page holds at most 100 rows unless a limit is given.",
+        "schema": {"type": "integer", "minimum": 1, "maximum": 1000, "default": 100}
+      },
+      "after": {
+        "name": "after", "in": "query",
+        "description": "The cursor of the page to return, taken from the X-Next-Cursor header of the previous page.",
+        "schema": {"type": "string"}
+      },
+      "sort": {
+        "name": "sort", "in": "query",
+        "description": "A comma-separated list of the fields to sort by, each prefixed with '-' to sort in descending order. Besides limit, after and sort, list endpoints accept filters of the form field=value or field.op=value, where op is one of eq, lt, lte, gt, gte, or prefix for string fields.",
+        "schema": {"type": "string"}
+      }
+    },
+    "headers": {
+      "NextCursor": {
+        "description": "The cursor of the next page, if there is one.",
+        "schema": {"type": "string"}
+      },
+      "Link": {
+        "description": "A link to the next page, with rel=\"next\", if there is one.",
+        "schema": {"type": "string"}
+      }
+    },
+    "requestBodies": {
+      "Customer": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Customer"}}}},
+      "Product": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Product"}}}},
+      "Order": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Order"}}}}
+    },
+    "responses": {
+      "Customer": {"description": "The customer.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Customer"}}}},
+      "Product": {"description": "The product.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Product"}}}},
+      "Order": {"description": "The order.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Order"}}}},
+      "Deleted": {"description": "The row was deleted.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+      "Problem": {
+        "description": "The request failed.",
+        "headers": {
+          "X-Request-ID": {"description": "The ID of the request.", "schema": {"type": "string"}}
+        },
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	ctx := context.Background()
+	doc, err := openapi3.NewLoader().LoadFromData(OpenAPISpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Validate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		method, path string
+		status       int
+		contentType  string
+		body         string
+		valid        bool
+	}{
+		{"GET", "/customer", 200, "application/json", `[{"id": 1, "name": "Billy"}]`, true},
+		{"GET", "/customer", 200, "application/json", `[{"id": "1", "name": "Billy"}]`, false},
+		{"GET", "/product/1", 200, "application/json", `{"id": 1, "name": "Ice Cream", "price": "123.4"}`, true},
+		{"GET", "/product/1", 200, "application/json", `{"id": 1, "name": "Ice Cream", "price": 123.4}`, false},
+		{"POST", "/order/1/product?productID=1", 200, "application/json",
+			`{"id": 1, "subtotal": "10", "customer": {"id": 1, "name": "Billy"}, "products": [{"id": 1, "name": "Ice Cream", "price": "123.4"}]}`, true},
+		{"GET", "/order", 200, "application/json", `[{"id": 1, "subtotal": "10", "customer": {"id": 1, "name": "Billy"}, "products": []}]`, true},
+		{"GET", "/order", 200, "application/json", `[{"id": 1, "subtotal": "10", "customer": {"id": 1}, "products": []}]`, false},
+		{"GET", "/order", 200, "application/json", `[{"id": 1, "subtotal": "10", "customer": {"id": 1, "name": "Billy"}, "products": null}]`, false},
+		{"GET", "/order/1", 200, "application/json", `{"id": 1, "subtotal": "10", "customer_id": 1}`, false},
+		{"DELETE", "/order/1", 200, "text/plain; charset=utf-8", "ok\n", true},
+		{"GET", "/order/1", 404, ProblemContentType, `{"type": "about:blank", "title": "Not Found", "status": 404}`, true},
+		{"GET", "/order/1", 404, "text/plain", "not found", false},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		route, pathParams, err := router.FindRoute(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.method, tc.path, err)
+		}
+		header := http.Header{"Content-Type": []string{tc.contentType}}
+		err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: &openapi3filter.RequestValidationInput{
+				Request: req, PathParams: pathParams, Route: route,
+			},
+			Status: tc.status,
+			Header: header,
+			Body:   ioutil.NopCloser(strings.NewReader(tc.body)),
+		})
+		if valid := err == nil; valid != tc.valid {
+			t.Errorf("%s %s %d %s: expected valid=%t, found %v", tc.method, tc.path, tc.status, tc.body, tc.valid, err)
+		}
+	}
+}
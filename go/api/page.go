@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	// DefaultPageSize is the number of rows of every page of a list endpoint
+	// whose client does not specify a limit, so that no list is returned
+	// unbounded.
+	DefaultPageSize = 100
+	// MaxPageSize is the largest limit a client may request.
+	MaxPageSize = 1000
+
+	// NextCursorHeader is the header that carries the cursor of the next page.
+	NextCursorHeader = "X-Next-Cursor"
+
+	limitParam = "limit"
+	afterParam = "after"
+)
+
+// Page describes the size of a page of a list endpoint's results. Pages are
+// paginated using the keyset of the last row of the previous page; see
+// ListQuery.
+type Page struct {
+	// Limit is the maximum number of rows of the page: the requested limit,
+	// which is at most MaxPageSize, or DefaultPageSize.
+	Limit int
+}
+
+func parsePage(q url.Values) (Page, error) {
+	page := Page{Limit: DefaultPageSize}
+	if s := q.Get(limitParam); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > MaxPageSize {
+			return Page{}, fmt.Errorf("query param %q must be an integer between 1 and %d", limitParam, MaxPageSize)
+		}
+		page.Limit = limit
+	}
+	return page, nil
+}
+
+// FetchLimit is the number of rows that should be fetched to serve the page.
+// One more row than the page holds is fetched to find out whether there is a
+// next page.
+func (p Page) FetchLimit() int {
+	return p.Limit + 1
+}
+
+// Trim returns how many of the n fetched rows belong to the page and whether
+// there is a next page.
+func (p Page) Trim(n int) (int, bool) {
+	if n > p.Limit {
+		return p.Limit, true
+	}
+	return n, false
+}
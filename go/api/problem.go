@@ -0,0 +1,145 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// ProblemContentType is the media type of Problem responses.
+	ProblemContentType = "application/problem+json"
+	// RequestIDHeader is the header that carries the ID of a request.
+	RequestIDHeader = "X-Request-ID"
+
+	// retryAfterSeconds is the Retry-After hint given to clients along with
+	// the 503 of a retryable transaction error.
+	retryAfterSeconds = 1
+)
+
+// Problem is an RFC 7807 problem details object. It is the body of every
+// error response returned by the Go servers.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	SQLState  string `json:"sqlstate,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// NewProblem creates a Problem with the given status and detail.
+func NewProblem(status int, detail string) *Problem {
+	title := http.StatusText(status)
+	if status == StatusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+	return &Problem{
+		Type:   "about:blank",
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// sqlStateDetails holds the details of the Problems of the database errors
+// that are the client's fault. The messages of these errors describe the
+// schema rather than the request, so they are not returned to the client.
+var sqlStateDetails = map[string]string{
+	// The names of products are the only unique values that clients choose.
+	CodeUniqueViolation:     "a product with this name already exists",
+	CodeForeignKeyViolation: "the request refers to an object that does not exist",
+	CodeNotNullViolation:    "a required field is missing",
+	CodeInvalidTextRepr:     "a field has an invalid value",
+}
+
+// WriteError writes err to w as a Problem with the given status. The details
+// of internal errors are logged instead of being returned to the client, and
+// database errors get a fixed detail for their SQLSTATE, so that only the
+// API's own errors, such as invalid input, are returned as they are.
+// Retryable transaction errors come with a Retry-After header.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	p := NewProblem(status, err.Error())
+	p.SQLState = SQLState(err)
+	if p.SQLState != "" {
+		p.Detail = sqlStateDetails[p.SQLState]
+	}
+	if status >= http.StatusInternalServerError {
+		switch {
+		case status == http.StatusServiceUnavailable && IsRetryable(err):
+			p.Detail = "transaction could not be completed due to contention, retry later"
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+		case status == http.StatusServiceUnavailable:
+			p.Detail = "service temporarily unavailable"
+		case status == http.StatusGatewayTimeout:
+			p.Detail = "request timed out"
+		default:
+			p.Detail = ""
+		}
+		p.RequestID = RequestID(w, r)
+		if l := loggerFromContext(r.Context()); l != nil {
+			l.logError(r.Context(), fmt.Sprintf("%s %s failed", r.Method, r.URL.Path), err)
+		} else {
+			log.Printf("request %s: %s %s: %v", p.RequestID, r.Method, r.URL.Path, err)
+		}
+	}
+	WriteProblem(w, r, p)
+}
+
+// WriteProblem writes p to w.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.RequestID == "" {
+		p.RequestID = RequestID(w, r)
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Printf("request %s: failed to encode problem: %v", p.RequestID, err)
+	}
+}
+
+// RequestID returns the ID of request r. The ID is taken from the request's
+// X-Request-ID header if the client set a valid one, and otherwise generated
+// and echoed on the response.
+func RequestID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		return id
+	}
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return id
+}
+
+// maxRequestIDLength bounds the length of the request IDs accepted from
+// clients.
+const maxRequestIDLength = 128
+
+// validRequestID reports whether a request ID set by a client is short and
+// printable enough to be logged and echoed.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
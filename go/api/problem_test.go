@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestWriteError(t *testing.T) {
+	const contention = "transaction could not be completed due to contention, retry later"
+	testCases := []struct {
+		err        error
+		status     int
+		title      string
+		detail     string
+		retryAfter string
+	}{
+		{
+			err:        &pgconn.PgError{Code: CodeSerializationFailure},
+			status:     http.StatusServiceUnavailable,
+			title:      "Service Unavailable",
+			detail:     contention,
+			retryAfter: "1",
+		},
+		{
+			err:        &RetriesExhaustedError{Err: &pgconn.PgError{Code: codeRetriableErrorLegacy}},
+			status:     http.StatusServiceUnavailable,
+			title:      "Service Unavailable",
+			detail:     contention,
+			retryAfter: "1",
+		},
+		{
+			// 503s that are not about contention get no Retry-After hint.
+			err:    errors.New("connection pool exhausted"),
+			status: http.StatusServiceUnavailable,
+			title:  "Service Unavailable",
+			detail: "service temporarily unavailable",
+		},
+		{
+			err:    fmt.Errorf("querying: %w", context.Canceled),
+			status: StatusClientClosedRequest,
+			title:  "Client Closed Request",
+			detail: "querying: context canceled",
+		},
+		{
+			// The messages of database errors are not returned to the client.
+			err: &pgconn.PgError{
+				Code:    CodeUniqueViolation,
+				Message: `duplicate key value violates unique constraint "products_name_key"`,
+			},
+			status: http.StatusConflict,
+			title:  "Conflict",
+			detail: "a product with this name already exists",
+		},
+		{
+			err:    &pgconn.PgError{Code: CodeInvalidTextRepr, Message: `could not parse "x" as type int`},
+			status: http.StatusBadRequest,
+			title:  "Bad Request",
+			detail: "a field has an invalid value",
+		},
+		{
+			// The API's own errors are returned as they are.
+			err:    json.Unmarshal([]byte("{"), new(Problem)),
+			status: http.StatusBadRequest,
+			title:  "Bad Request",
+			detail: "unexpected end of JSON input",
+		},
+	}
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		status := StatusCode(tc.err)
+		// A 503 that is not about contention is not classified as such by
+		// StatusCode, so it is written as one here.
+		if tc.status == http.StatusServiceUnavailable {
+			status = tc.status
+		} else if status != tc.status {
+			t.Errorf("expected %v to map to %d, found %d", tc.err, tc.status, status)
+		}
+		WriteError(w, httptest.NewRequest("POST", "/order", nil), status, tc.err)
+
+		var p Problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Title != tc.title || p.Detail != tc.detail {
+			t.Errorf("%v: expected %q: %q, found %q: %q", tc.err, tc.title, tc.detail, p.Title, p.Detail)
+		}
+		if found := w.Header().Get("Retry-After"); found != tc.retryAfter {
+			t.Errorf("%v: expected Retry-After %q, found %q", tc.err, tc.retryAfter, found)
+		}
+	}
+}
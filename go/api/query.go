@@ -0,0 +1,430 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const sortParam = "sort"
+
+// FieldType is the type of a Field. It determines how filter values are
+// parsed and which filter operators apply.
+type FieldType int
+
+const (
+	// IntField is an integer column.
+	IntField FieldType = iota
+	// DecimalField is a DECIMAL column.
+	DecimalField
+	// StringField is a STRING column.
+	StringField
+)
+
+// Field is a column of a list endpoint that clients may filter and sort by.
+type Field struct {
+	// Name is the name of the field in query parameters.
+	Name   string
+	Column string
+	Type   FieldType
+	// Nullable is set for numeric columns that may hold NULL, which the ORMs
+	// read as zero. They are filtered and sorted as if NULL were zero, so that
+	// results are consistent with what clients see.
+	Nullable bool
+}
+
+func (f Field) expr() string {
+	if f.Nullable {
+		return fmt.Sprintf("COALESCE(%s, 0)", f.Column)
+	}
+	return f.Column
+}
+
+// placeholder returns the placeholder of a value compared with the field.
+// Decimals are passed as strings and compared as DECIMALs, so that they are
+// compared with the column without losing precision.
+func (f Field) placeholder() string {
+	if f.Type == DecimalField {
+		return "CAST(? AS DECIMAL)"
+	}
+	return "?"
+}
+
+func (f Field) parseValue(s string) (interface{}, error) {
+	switch f.Type {
+	case IntField:
+		return strconv.ParseInt(s, 10, 64)
+	case DecimalField:
+		// Decimals are validated here but passed on as strings; see
+		// placeholder.
+		d, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(d) || math.IsInf(d, 0) {
+			return nil, fmt.Errorf("%s is not a finite number", s)
+		}
+		return s, nil
+	default:
+		return s, nil
+	}
+}
+
+// Fields are the fields of a list endpoint. The first field must be the
+// primary key, which orders rows that compare equal on all sort keys.
+type Fields []Field
+
+func (fs Fields) lookup(name string) (Field, bool) {
+	for _, f := range fs {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Row holds the values of the Fields of a row, keyed by field name.
+type Row map[string]interface{}
+
+// filterOps maps the filter operators that clients may use to SQL operators.
+var filterOps = map[string]string{
+	"eq":  "=",
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+// prefixOp is the filter operator that matches string fields by prefix.
+const prefixOp = "prefix"
+
+// ListQuery is a parsed request to a list endpoint. It consists of:
+//
+//   - filters, given as query parameters of the form field=value or
+//     field.op=value, where op is one of eq, lt, lte, gt and gte, or prefix
+//     for string fields. All filters must match.
+//   - a sort order, given as a comma-separated list of fields, each of which
+//     may be prefixed with '-' to sort in descending order. For example,
+//     sort=-price,name.
+//   - the page of results to return; see Page.
+//
+// Results are paginated by keyset: the cursor of the next page holds the
+// values of the sort keys of the last row returned, and the next page
+// continues from the first row that sorts after them.
+type ListQuery struct {
+	Page
+
+	fields    Fields
+	sortParam string
+	sort      []sortKey
+
+	conds []string
+	args  []interface{}
+
+	// filters and keyset hold the conditions of conds in the form that
+	// Match evaluates them in.
+	filters []filter
+	keyset  []interface{}
+
+	// exactDecimals holds the values of the decimal sort keys of the last row
+	// of the page, if they were set; see ExactDecimalsQuery.
+	exactDecimals []string
+}
+
+// filter is a condition on a field. For the prefix operator, value is the
+// prefix.
+type filter struct {
+	field Field
+	op    string
+	value interface{}
+}
+
+type sortKey struct {
+	field Field
+	desc  bool
+}
+
+// ParseListQuery parses the query parameters of a request to a list endpoint
+// with the given fields.
+func (fs Fields) ParseListQuery(r *http.Request) (*ListQuery, error) {
+	q := r.URL.Query()
+	page, err := parsePage(q)
+	if err != nil {
+		return nil, err
+	}
+	lq := &ListQuery{
+		Page:      page,
+		fields:    fs,
+		sortParam: q.Get(sortParam),
+	}
+	if err := lq.parseSort(); err != nil {
+		return nil, err
+	}
+
+	params := make([]string, 0, len(q))
+	for param := range q {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		switch param {
+		case limitParam, afterParam, sortParam:
+			continue
+		}
+		name, op := param, "eq"
+		if i := strings.IndexByte(param, '.'); i >= 0 {
+			name, op = param[:i], param[i+1:]
+		}
+		f, ok := fs.lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown query param %q", param)
+		}
+		for _, value := range q[param] {
+			if err := lq.addFilter(f, op, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if s := q.Get(afterParam); s != "" {
+		if err := lq.addKeyset(s); err != nil {
+			return nil, err
+		}
+	}
+	return lq, nil
+}
+
+func (lq *ListQuery) parseSort() error {
+	pk := lq.fields[0]
+	seen := make(map[string]bool)
+	if lq.sortParam != "" {
+		for _, name := range strings.Split(lq.sortParam, ",") {
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(name, "-")
+			f, ok := lq.fields.lookup(name)
+			if !ok {
+				return fmt.Errorf("cannot sort by unknown field %q", name)
+			}
+			if seen[name] {
+				return fmt.Errorf("cannot sort by field %q more than once", name)
+			}
+			seen[name] = true
+			lq.sort = append(lq.sort, sortKey{field: f, desc: desc})
+		}
+	}
+	if !seen[pk.Name] {
+		lq.sort = append(lq.sort, sortKey{field: pk})
+	}
+	return nil
+}
+
+func (lq *ListQuery) addFilter(f Field, op, s string) error {
+	value, err := f.parseValue(s)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for field %q: %v", s, f.Name, err)
+	}
+	if op == prefixOp {
+		if f.Type != StringField {
+			return fmt.Errorf("filter operator %q does not apply to field %q", op, f.Name)
+		}
+		lq.where(f.expr()+" LIKE ?", likePrefix(s))
+		lq.filters = append(lq.filters, filter{field: f, op: op, value: s})
+		return nil
+	}
+	sqlOp, ok := filterOps[op]
+	if !ok {
+		return fmt.Errorf("unknown filter operator %q for field %q", op, f.Name)
+	}
+	lq.where(fmt.Sprintf("%s %s %s", f.expr(), sqlOp, f.placeholder()), value)
+	lq.filters = append(lq.filters, filter{field: f, op: op, value: value})
+	return nil
+}
+
+// addKeyset restricts the results to the rows that sort after the ones
+// described by cursor.
+func (lq *ListQuery) addKeyset(s string) error {
+	values, err := lq.decodeCursor(s)
+	if err != nil {
+		return fmt.Errorf("invalid query param %q: %v", afterParam, err)
+	}
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with < for descending keys.
+	var disjuncts []string
+	var args []interface{}
+	for i, key := range lq.sort {
+		var conjuncts []string
+		for j, prev := range lq.sort[:i] {
+			conjuncts = append(conjuncts, prev.field.expr()+" = "+prev.field.placeholder())
+			args = append(args, values[j])
+		}
+		op := ">"
+		if key.desc {
+			op = "<"
+		}
+		conjuncts = append(conjuncts, fmt.Sprintf("%s %s %s", key.field.expr(), op, key.field.placeholder()))
+		args = append(args, values[i])
+		disjuncts = append(disjuncts, "("+strings.Join(conjuncts, " AND ")+")")
+	}
+	lq.where("("+strings.Join(disjuncts, " OR ")+")", args...)
+	lq.keyset = values
+	return nil
+}
+
+func (lq *ListQuery) where(cond string, args ...interface{}) {
+	lq.conds = append(lq.conds, cond)
+	lq.args = append(lq.args, args...)
+}
+
+// Where returns the condition that the rows of the results satisfy, using ?
+// placeholders for its arguments.
+func (lq *ListQuery) Where() (string, []interface{}) {
+	if len(lq.conds) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(lq.conds, " AND "), lq.args
+}
+
+// OrderBy returns the ORDER BY expression that sorts the results.
+func (lq *ListQuery) OrderBy() string {
+	keys := make([]string, len(lq.sort))
+	for i, key := range lq.sort {
+		dir := "ASC"
+		if key.desc {
+			dir = "DESC"
+		}
+		keys[i] = key.field.expr() + " " + dir
+	}
+	return strings.Join(keys, ", ")
+}
+
+// SetNext advertises the page that follows the row last, through a Link
+// header and the cursor header.
+func (lq *ListQuery) SetNext(w http.ResponseWriter, r *http.Request, last Row) {
+	cursor := lq.encodeCursor(last)
+	next := *r.URL
+	q := next.Query()
+	q.Set(limitParam, strconv.Itoa(lq.Limit))
+	q.Set(afterParam, cursor)
+	next.RawQuery = q.Encode()
+	w.Header().Set(NextCursorHeader, cursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
+
+// ExactDecimalsQuery returns a query of the values of the decimal fields that
+// the results are sorted by, as strings, in the row of table whose primary key
+// is the query's argument, along with the number of values. It returns "" if
+// the results are not sorted by a decimal field. Applications that read
+// DECIMAL columns into float64, which cannot hold every decimal exactly, run
+// it for the last row of a page that has a next one, and pass the values to
+// SetExactDecimals, so that the cursor of the next page holds the decimals
+// as the database stores them.
+func (lq *ListQuery) ExactDecimalsQuery(table string) (string, int) {
+	var exprs []string
+	for _, key := range lq.sort {
+		if key.field.Type == DecimalField {
+			exprs = append(exprs, key.field.expr()+"::STRING")
+		}
+	}
+	if len(exprs) == 0 {
+		return "", 0
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?",
+		strings.Join(exprs, ", "), table, lq.fields[0].Column), len(exprs)
+}
+
+// SetExactDecimals sets the values of the decimal sort keys of the last row
+// of the page, as returned by ExactDecimalsQuery, which take precedence over
+// the values of the row passed to SetNext.
+func (lq *ListQuery) SetExactDecimals(values []string) {
+	lq.exactDecimals = values
+}
+
+// cursor is the decoded form of the opaque cursors handed out to clients.
+type cursor struct {
+	// Sort is the sort order of the results the cursor points into.
+	Sort   string        `json:"s,omitempty"`
+	Values []interface{} `json:"v"`
+}
+
+// encodeCursor encodes the sort keys of last into a cursor. Decimals are
+// encoded as strings, taken from exactDecimals if they are set; otherwise,
+// they are the float64 values of the row, which are exact if the values were
+// float64s to begin with.
+func (lq *ListQuery) encodeCursor(last Row) string {
+	c := cursor{Sort: lq.sortParam}
+	decimals := lq.exactDecimals
+	for _, key := range lq.sort {
+		var value interface{}
+		switch v := last[key.field.Name].(type) {
+		case *string:
+			if v != nil {
+				value = *v
+			}
+		case float64:
+			if len(decimals) > 0 {
+				value, decimals = decimals[0], decimals[1:]
+			} else {
+				value = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		default:
+			value = v
+		}
+		c.Values = append(c.Values, value)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (lq *ListQuery) decodeCursor(s string) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a valid cursor", s)
+	}
+	var c cursor
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || len(c.Values) != len(lq.sort) {
+		return nil, fmt.Errorf("%q is not a valid cursor", s)
+	}
+	if c.Sort != lq.sortParam {
+		return nil, fmt.Errorf("cursor %q does not match sort order %q", s, lq.sortParam)
+	}
+	values := make([]interface{}, len(c.Values))
+	for i, key := range lq.sort {
+		var err error
+		switch v := c.Values[i].(type) {
+		case json.Number:
+			if key.field.Type == IntField {
+				values[i], err = v.Int64()
+			} else {
+				err = fmt.Errorf("unexpected number")
+			}
+		case string:
+			if key.field.Type == IntField {
+				err = fmt.Errorf("unexpected string")
+			} else {
+				values[i], err = key.field.parseValue(v)
+			}
+		default:
+			err = fmt.Errorf("unexpected value %v", v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid cursor: %v", s, err)
+		}
+	}
+	return values, nil
+}
+
+// likePrefix returns a LIKE pattern that matches strings starting with s.
+func likePrefix(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s) + "%"
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var testFields = Fields{
+	{Name: "id", Column: "id", Type: IntField},
+	{Name: "name", Column: "name", Type: StringField},
+	{Name: "price", Column: "price", Type: DecimalField, Nullable: true},
+}
+
+func TestListQuery(t *testing.T) {
+	testCases := []struct {
+		query     string
+		where     string
+		args      []interface{}
+		orderBy   string
+		limit     int
+		expectErr bool
+	}{
+		{
+			query:   "",
+			where:   "TRUE",
+			orderBy: "id ASC",
+			limit:   DefaultPageSize,
+		},
+		{
+			query:   "name.prefix=Ice_&price.gte=1.5&price.lt=10&limit=5",
+			where:   "name LIKE ? AND COALESCE(price, 0) >= CAST(? AS DECIMAL) AND COALESCE(price, 0) < CAST(? AS DECIMAL)",
+			args:    []interface{}{`Ice\_%`, "1.5", "10"},
+			orderBy: "id ASC",
+			limit:   5,
+		},
+		{
+			query:   "id=7&sort=-price,name",
+			where:   "id = ?",
+			args:    []interface{}{int64(7)},
+			orderBy: "COALESCE(price, 0) DESC, name ASC, id ASC",
+			limit:   DefaultPageSize,
+		},
+		{query: "sort=-id,name", where: "TRUE", orderBy: "id DESC, name ASC", limit: DefaultPageSize},
+		{query: "limit=0", expectErr: true},
+		{query: "limit=1001", expectErr: true},
+		{query: "color=red", expectErr: true},
+		{query: "price.like=1", expectErr: true},
+		{query: "price.prefix=1", expectErr: true},
+		{query: "price=cheap", expectErr: true},
+		{query: "price=NaN", expectErr: true},
+		{query: "id=1.5", expectErr: true},
+		{query: "sort=color", expectErr: true},
+		{query: "sort=name,-name", expectErr: true},
+		{query: "after=!!", expectErr: true},
+	}
+	for _, tc := range testCases {
+		lq, err := testFields.ParseListQuery(httptest.NewRequest("GET", "/product?"+tc.query, nil))
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%q: expected error", tc.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.query, err)
+			continue
+		}
+		if where, args := lq.Where(); where != tc.where || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("%q: expected where %q %v, found %q %v", tc.query, tc.where, tc.args, where, args)
+		}
+		if orderBy := lq.OrderBy(); orderBy != tc.orderBy {
+			t.Errorf("%q: expected order by %q, found %q", tc.query, tc.orderBy, orderBy)
+		}
+		if lq.Limit != tc.limit {
+			t.Errorf("%q: expected limit %d, found %d", tc.query, tc.limit, lq.Limit)
+		}
+	}
+}
+
+func TestListQueryCursor(t *testing.T) {
+	r := httptest.NewRequest("GET", "/product?sort=-price&limit=2", nil)
+	lq, err := testFields.ParseListQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, more := lq.Trim(lq.FetchLimit()); n != 2 || !more {
+		t.Fatalf("expected a full page with more to come, found n=%d more=%t", n, more)
+	}
+
+	w := httptest.NewRecorder()
+	name := "Ice Cream"
+	lq.SetNext(w, r, Row{"id": 42, "name": &name, "price": 123.4})
+	cursor := w.Header().Get(NextCursorHeader)
+	expLink := `</product?after=` + cursor + `&limit=2&sort=-price>; rel="next"`
+	if link := w.Header().Get("Link"); link != expLink {
+		t.Fatalf("expected Link header %s, found %s", expLink, link)
+	}
+
+	next, err := testFields.ParseListQuery(httptest.NewRequest("GET", "/product?sort=-price&limit=2&after="+cursor, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expWhere := "((COALESCE(price, 0) < CAST(? AS DECIMAL)) OR (COALESCE(price, 0) = CAST(? AS DECIMAL) AND id > ?))"
+	expArgs := []interface{}{"123.4", "123.4", int64(42)}
+	if where, args := next.Where(); where != expWhere || !reflect.DeepEqual(args, expArgs) {
+		t.Fatalf("expected where %q %v, found %q %v", expWhere, expArgs, where, args)
+	}
+
+	// Match evaluates the keyset like the condition above.
+	for _, tc := range []struct {
+		row      Row
+		expected bool
+	}{
+		{Row{"id": 1, "name": "Cone", "price": 200.0}, false},
+		{Row{"id": 41, "name": "Cone", "price": 123.4}, false},
+		{Row{"id": 42, "name": "Ice Cream", "price": 123.4}, false},
+		{Row{"id": 43, "name": "Fudge", "price": 123.4}, true},
+		{Row{"id": 1, "name": "Fudge", "price": 1.0}, true},
+	} {
+		if matched := next.Match(tc.row); matched != tc.expected {
+			t.Errorf("Match(%v) = %t, expected %t", tc.row, matched, tc.expected)
+		}
+	}
+
+	// A cursor cannot be used with a different sort order.
+	if _, err := testFields.ParseListQuery(httptest.NewRequest("GET", "/product?after="+cursor, nil)); err == nil {
+		t.Fatal("expected error using cursor with a different sort order")
+	}
+}
+
+func TestListQueryExactDecimals(t *testing.T) {
+	r := httptest.NewRequest("GET", "/product?sort=-price,name&limit=2", nil)
+	lq, err := testFields.ParseListQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, n := lq.ExactDecimalsQuery("products")
+	if expQuery := "SELECT COALESCE(price, 0)::STRING FROM products WHERE id = ?"; query != expQuery || n != 1 {
+		t.Fatalf("expected query %q with 1 value, found %q with %d", expQuery, query, n)
+	}
+
+	// The float64 of the row cannot hold the decimal that the database
+	// stores, so the cursor holds the exact one.
+	exact := "1234567890123456.78"
+	lq.SetExactDecimals([]string{exact})
+	w := httptest.NewRecorder()
+	lq.SetNext(w, r, Row{"id": 42, "name": "Ice Cream", "price": 1234567890123456.78})
+	next, err := testFields.ParseListQuery(httptest.NewRequest("GET", "/product?sort=-price,name&limit=2&after="+w.Header().Get(NextCursorHeader), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, args := next.Where(); len(args) == 0 || args[0] != exact {
+		t.Fatalf("expected the cursor to hold %s, found %v", exact, args)
+	}
+
+	byName, err := testFields.ParseListQuery(httptest.NewRequest("GET", "/product?sort=name", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query, _ := byName.ExactDecimalsQuery("products"); query != "" {
+		t.Fatalf("expected no query for a sort by name, found %q", query)
+	}
+}
+
+func TestListQueryMatch(t *testing.T) {
+	lq, err := testFields.ParseListQuery(httptest.NewRequest("GET", "/product?name.prefix=Ice&price.gte=1.5&price.lt=10&sort=-price,name", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []Row{
+		{"id": 1, "name": "Ice Cream", "price": 2.0},
+		{"id": 2, "name": "Ice Cube", "price": 10.0},
+		{"id": 3, "name": "Ice Pop", "price": 1.5},
+		{"id": 4, "name": "Fudge", "price": 5.0},
+		{"id": 5, "name": "Ice Age", "price": 2.0},
+		{"id": 6, "name": "ice cream", "price": 2.0},
+	}
+	var matched []Row
+	for _, row := range rows {
+		if lq.Match(row) {
+			matched = append(matched, row)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return lq.Compare(matched[i], matched[j]) < 0 })
+	var ids []int
+	for _, row := range matched {
+		ids = append(ids, row["id"].(int))
+	}
+	if expected := []int{5, 1, 3}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected rows %v, found %v", expected, ids)
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Recover returns a handler that runs h and recovers from its panics, which
+// are logged along with the ID of the request and counted in recovered. If h
+// has not started writing a response when it panics, the client is sent a 500
+// Problem carrying the request ID; otherwise the response is left as is, since
+// a second response cannot be written.
+func Recover(h http.Handler, recovered prometheus.Counter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newResponseRecorder(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// The handler aborted the response on purpose.
+				panic(v)
+			}
+			if recovered != nil {
+				recovered.Inc()
+			}
+			id := RequestID(w, r)
+			msg := fmt.Sprintf("%s %s panicked: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+			if l := loggerFromContext(r.Context()); l != nil {
+				l.Errorf(r.Context(), "%s", msg)
+			} else {
+				log.Printf("request %s: %s", id, msg)
+			}
+			if rec.status != 0 {
+				return
+			}
+			p := NewProblem(http.StatusInternalServerError, "")
+			p.RequestID = id
+			WriteProblem(w, r, p)
+		}()
+		h.ServeHTTP(rec, r)
+	})
+}
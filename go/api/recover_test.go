@@ -0,0 +1,52 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecover(t *testing.T) {
+	panics := prometheus.NewCounter(prometheus.CounterOpts{Name: "panics"})
+	var logs bytes.Buffer
+	l := NewLogger(&logs)
+
+	h := l.LogRequests(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), panics))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/customer/1", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, found %d", http.StatusInternalServerError, w.Code)
+	}
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if id := w.Header().Get(RequestIDHeader); p.RequestID == "" || p.RequestID != id {
+		t.Errorf("expected problem to carry request ID %q, found %q", id, p.RequestID)
+	}
+	if n := testutil.ToFloat64(panics); n != 1 {
+		t.Errorf("expected 1 recovered panic, found %v", n)
+	}
+	if !bytes.Contains(logs.Bytes(), []byte("panicked: boom")) {
+		t.Errorf("expected the panic to be logged, found %s", logs.String())
+	}
+
+	// A panic after the response was started must not produce a second one.
+	h = Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	}), panics)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/customer/1", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected the started response to be left as is, found %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// unmatchedRoute labels the requests that match no route.
+const unmatchedRoute = "unmatched"
+
+// matchedRoute holds the pattern of the route that served a request, such as
+// /order/:orderID/product, so that middleware can label requests by route
+// rather than by their paths, which carry IDs.
+type matchedRoute struct {
+	pattern string
+}
+
+type matchedRouteKey struct{}
+
+// withMatchedRoute returns r with a matchedRoute in its context, which the
+// handlers registered by handleRoute fill in, along with that matchedRoute.
+// Middleware that is nested in other middleware shares its matchedRoute.
+func withMatchedRoute(r *http.Request) (*http.Request, *matchedRoute) {
+	if m, ok := r.Context().Value(matchedRouteKey{}).(*matchedRoute); ok {
+		return r, m
+	}
+	m := &matchedRoute{}
+	return r.WithContext(context.WithValue(r.Context(), matchedRouteKey{}, m)), m
+}
+
+// String returns the pattern of the route, or unmatchedRoute if no route
+// served the request.
+func (m *matchedRoute) String() string {
+	if m.pattern == "" {
+		return unmatchedRoute
+	}
+	return m.pattern
+}
+
+// handleRoute registers handle on router for requests with method that match
+// pattern, recording pattern as the route of the requests that it serves for
+// middleware such as Metrics.Instrument.
+func handleRoute(router *httprouter.Router, method, pattern string, handle httprouter.Handle) {
+	router.Handle(method, pattern, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if m, ok := r.Context().Value(matchedRouteKey{}).(*matchedRoute); ok {
+			m.pattern = pattern
+		}
+		handle(w, r, ps)
+	})
+}
+
+// handlerRoute is like handleRoute, for an http.Handler.
+func handlerRoute(router *httprouter.Router, method, pattern string, h http.Handler) {
+	handleRoute(router, method, pattern, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,432 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Server is an http server that handles the REST requests of a sample
+// application by delegating to its Store.
+type Server struct {
+	app     string
+	store   Store
+	metrics *Metrics
+	checks  []ReadinessCheck
+}
+
+// NewServer creates a new instance of a Server for the given app, which
+// stores its data in store and records its metrics in metrics. The server is
+// ready to serve requests once all checks pass.
+func NewServer(app string, store Store, metrics *Metrics, checks ...ReadinessCheck) *Server {
+	return &Server{app: app, store: store, metrics: metrics, checks: checks}
+}
+
+// RegisterRouter registers a router onto the Server. The routes record their
+// patterns, by which the metrics label requests.
+func (s *Server) RegisterRouter(router *httprouter.Router) {
+	handleRoute(router, http.MethodGet, "/ping", s.ping)
+	handlerRoute(router, http.MethodGet, "/healthz", Healthz(s.app))
+	handlerRoute(router, http.MethodGet, "/readyz", Readyz(s.app, s.checks...))
+	handlerRoute(router, http.MethodGet, "/metrics", s.metrics.Handler())
+	handlerRoute(router, http.MethodGet, OpenAPIPath, OpenAPIHandler())
+
+	handleRoute(router, http.MethodGet, "/customer", s.getCustomers)
+	handleRoute(router, http.MethodPost, "/customer", s.createCustomer)
+	handleRoute(router, http.MethodGet, "/customer/:customerID", s.getCustomer)
+	handleRoute(router, http.MethodPut, "/customer/:customerID", s.updateCustomer)
+	handleRoute(router, http.MethodDelete, "/customer/:customerID", s.deleteCustomer)
+
+	handleRoute(router, http.MethodGet, "/product", s.getProducts)
+	handleRoute(router, http.MethodPost, "/product", s.createProduct)
+	handleRoute(router, http.MethodGet, "/product/:productID", s.getProduct)
+	handleRoute(router, http.MethodPut, "/product/:productID", s.updateProduct)
+	handleRoute(router, http.MethodDelete, "/product/:productID", s.deleteProduct)
+
+	handleRoute(router, http.MethodGet, "/order", s.getOrders)
+	handleRoute(router, http.MethodPost, "/order", s.createOrder)
+	handleRoute(router, http.MethodGet, "/order/:orderID", s.getOrder)
+	handleRoute(router, http.MethodPut, "/order/:orderID", s.updateOrder)
+	handleRoute(router, http.MethodDelete, "/order/:orderID", s.deleteOrder)
+	handleRoute(router, http.MethodPost, "/order/:orderID/product", s.addProductToOrder)
+}
+
+// Handler returns a handler of the Server's routes, wrapped in the middleware
+// that the sample applications share: each request is bounded by
+// requestTimeout, recovered from if it panics, traced, counted in the
+// Server's metrics and logged to logger.
+func (s *Server) Handler(logger *Logger, requestTimeout time.Duration) http.Handler {
+	router := httprouter.New()
+	s.RegisterRouter(router)
+
+	var handler http.Handler = router
+	handler = WithRequestTimeout(handler, requestTimeout)
+	handler = Recover(handler, s.metrics.PanicsRecovered)
+	handler = Trace(handler)
+	handler = s.metrics.Instrument(handler)
+	handler = logger.LogRequests(handler)
+	return handler
+}
+
+func (s *Server) ping(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	writeTextResult(w, s.app)
+}
+
+func (s *Server) getCustomers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	lq, err := customerFields.ParseListQuery(r)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	customers, err := s.store.ListCustomers(r.Context(), lq)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	n, more := lq.Trim(len(customers))
+	customers = customers[:n]
+	if more {
+		lq.SetNext(w, r, CustomerRow(customers[n-1]))
+	}
+	writeJSONResult(w, r, customers)
+}
+
+func (s *Server) createCustomer(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input Customer
+	if !decodeBody(w, r, &input) {
+		return
+	}
+
+	customer, err := s.store.CreateCustomer(r.Context(), input)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSONResult(w, r, customer)
+}
+
+func (s *Server) getCustomer(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "customerID")
+	if !ok {
+		return
+	}
+
+	customer, err := s.store.GetCustomer(r.Context(), id)
+	if err != nil {
+		writeError(w, r, notFound(err, "customer", id))
+		return
+	}
+	writeJSONResult(w, r, customer)
+}
+
+func (s *Server) updateCustomer(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "customerID")
+	if !ok {
+		return
+	}
+	var input Customer
+	if !decodeBody(w, r, &input) {
+		return
+	}
+
+	customer, err := s.store.UpdateCustomer(r.Context(), id, input)
+	if err != nil {
+		writeError(w, r, notFound(err, "customer", id))
+		return
+	}
+	writeJSONResult(w, r, customer)
+}
+
+func (s *Server) deleteCustomer(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "customerID")
+	if !ok {
+		return
+	}
+
+	if err := s.store.DeleteCustomer(r.Context(), id); err != nil {
+		writeError(w, r, notFound(err, "customer", id))
+		return
+	}
+	writeTextResult(w, "ok")
+}
+
+func (s *Server) getProducts(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	lq, err := productFields.ParseListQuery(r)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	products, err := s.store.ListProducts(r.Context(), lq)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	n, more := lq.Trim(len(products))
+	products = products[:n]
+	if more {
+		lq.SetNext(w, r, ProductRow(products[n-1]))
+	}
+	writeJSONResult(w, r, products)
+}
+
+func (s *Server) createProduct(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input Product
+	if !decodeBody(w, r, &input) {
+		return
+	}
+
+	product, err := s.store.CreateProduct(r.Context(), input)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSONResult(w, r, product)
+}
+
+func (s *Server) getProduct(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "productID")
+	if !ok {
+		return
+	}
+
+	product, err := s.store.GetProduct(r.Context(), id)
+	if err != nil {
+		writeError(w, r, notFound(err, "product", id))
+		return
+	}
+	writeJSONResult(w, r, product)
+}
+
+func (s *Server) updateProduct(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "productID")
+	if !ok {
+		return
+	}
+	var input Product
+	if !decodeBody(w, r, &input) {
+		return
+	}
+
+	product, err := s.store.UpdateProduct(r.Context(), id, input)
+	if err != nil {
+		writeError(w, r, notFound(err, "product", id))
+		return
+	}
+	writeJSONResult(w, r, product)
+}
+
+func (s *Server) deleteProduct(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "productID")
+	if !ok {
+		return
+	}
+
+	if err := s.store.DeleteProduct(r.Context(), id); err != nil {
+		writeError(w, r, notFound(err, "product", id))
+		return
+	}
+	writeTextResult(w, "ok")
+}
+
+func (s *Server) getOrders(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	lq, err := orderFields.ParseListQuery(r)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	orders, err := s.store.ListOrders(r.Context(), lq)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	n, more := lq.Trim(len(orders))
+	orders = orders[:n]
+	if more {
+		lq.SetNext(w, r, OrderRow(orders[n-1]))
+	}
+	for i := range orders {
+		orders[i] = normalizeOrder(orders[i])
+	}
+	writeJSONResult(w, r, orders)
+}
+
+func (s *Server) createOrder(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input Order
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	if !validateOrder(w, r, input) {
+		return
+	}
+
+	order, err := s.store.CreateOrder(r.Context(), input)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSONResult(w, r, normalizeOrder(order))
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "orderID")
+	if !ok {
+		return
+	}
+
+	order, err := s.store.GetOrder(r.Context(), id)
+	if err != nil {
+		writeError(w, r, notFound(err, "order", id))
+		return
+	}
+	writeJSONResult(w, r, normalizeOrder(order))
+}
+
+func (s *Server) updateOrder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "orderID")
+	if !ok {
+		return
+	}
+	var input Order
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	if !validateOrder(w, r, input) {
+		return
+	}
+
+	order, err := s.store.UpdateOrder(r.Context(), id, input)
+	if err != nil {
+		writeError(w, r, notFound(err, "order", id))
+		return
+	}
+	writeJSONResult(w, r, normalizeOrder(order))
+}
+
+func (s *Server) deleteOrder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := parseID(w, r, ps, "orderID")
+	if !ok {
+		return
+	}
+
+	if err := s.store.DeleteOrder(r.Context(), id); err != nil {
+		writeError(w, r, notFound(err, "order", id))
+		return
+	}
+	writeTextResult(w, "ok")
+}
+
+func (s *Server) addProductToOrder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	orderID, ok := parseID(w, r, ps, "orderID")
+	if !ok {
+		return
+	}
+
+	const productIDParam = "productID"
+	productIDString := r.URL.Query().Get(productIDParam)
+	if productIDString == "" {
+		writeMissingParamError(w, r, productIDParam)
+		return
+	}
+	productID, err := strconv.Atoi(productIDString)
+	if err != nil {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, fmt.Sprintf("invalid query param %q: %q is not an integer", productIDParam, productIDString)))
+		return
+	}
+
+	order, err := s.store.AddProductToOrder(r.Context(), orderID, productID)
+	if err != nil {
+		writeError(w, r, notFound(err, "order", orderID))
+		return
+	}
+	writeJSONResult(w, r, normalizeOrder(order))
+}
+
+// decodeBody decodes the JSON body of r into v, and otherwise writes a 400.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		WriteError(w, r, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// validateOrder checks that an order refers to its customer and products by
+// ID, and otherwise writes a 400.
+func validateOrder(w http.ResponseWriter, r *http.Request, order Order) bool {
+	if order.Customer.ID == 0 {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "must specify user"))
+		return false
+	}
+	for _, product := range order.Products {
+		if product.ID == 0 {
+			WriteProblem(w, r, NewProblem(http.StatusBadRequest, "must specify a product ID"))
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeOrder returns o with an empty rather than a nil list of products,
+// which ORMs differ on for orders without products.
+func normalizeOrder(o Order) Order {
+	if o.Products == nil {
+		o.Products = []Product{}
+	}
+	return o
+}
+
+// parseID parses the ID in the path parameter with the given name, and
+// otherwise writes a 400.
+func parseID(w http.ResponseWriter, r *http.Request, ps httprouter.Params, name string) (int, bool) {
+	s := ps.ByName(name)
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, fmt.Sprintf("invalid %s: %q is not an integer", name, s)))
+		return 0, false
+	}
+	return id, true
+}
+
+// notFound describes an ErrNotFound returned for the entity with the given
+// ID, and returns any other error as is.
+func notFound(err error, entity string, id int) error {
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%s %d %w", entity, id, ErrNotFound)
+	}
+	return err
+}
+
+func writeTextResult(w http.ResponseWriter, res string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, res)
+}
+
+func writeJSONResult(w http.ResponseWriter, r *http.Request, res interface{}) {
+	// Encode the result before writing the header, so that an encoding error
+	// can still be reported as such.
+	b, err := json.Marshal(res)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(b, '\n'))
+}
+
+func writeMissingParamError(w http.ResponseWriter, r *http.Request, paramName string) {
+	WriteProblem(w, r, NewProblem(http.StatusBadRequest, fmt.Sprintf("missing query param %q", paramName)))
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	WriteError(w, r, StatusCode(err), err)
+}
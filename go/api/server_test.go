@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// stubStore is a Store that only knows the customer with ID 1 and the orders
+// that it is asked to create. Methods that the tests do not call panic.
+type stubStore struct {
+	Store
+	updatedID int
+}
+
+func (s *stubStore) GetCustomer(_ context.Context, id int) (Customer, error) {
+	if id != 1 {
+		return Customer{}, ErrNotFound
+	}
+	return Customer{ID: 1, Name: "Alice"}, nil
+}
+
+func (s *stubStore) UpdateCustomer(_ context.Context, id int, c Customer) (Customer, error) {
+	s.updatedID = id
+	c.ID = id
+	return c, nil
+}
+
+func (s *stubStore) CreateOrder(_ context.Context, o Order) (Order, error) {
+	if o.Customer.ID != 1 {
+		return Order{}, &ReferenceError{Entity: "customer", ID: o.Customer.ID}
+	}
+	return Order{ID: 1, Customer: Customer{ID: 1, Name: "Alice"}}, nil
+}
+
+func TestServer(t *testing.T) {
+	store := &stubStore{}
+	router := httprouter.New()
+	NewServer("go/test", store, NewMetrics()).RegisterRouter(router)
+
+	testCases := []struct {
+		method, path, body string
+		code               int
+		expected           string
+	}{
+		{"GET", "/ping", "", http.StatusOK, "go/test\n"},
+		{"GET", "/customer/1", "", http.StatusOK, `{"id":1,"name":"Alice"}` + "\n"},
+		{"GET", "/customer/2", "", http.StatusNotFound, "customer 2 not found"},
+		{"GET", "/customer/abc", "", http.StatusBadRequest, `invalid customerID: \"abc\" is not an integer`},
+		{"PUT", "/customer/7", `{"id":1,"name":"Bob"}`, http.StatusOK, `{"id":7,"name":"Bob"}` + "\n"},
+		{"PUT", "/customer/7", `{"name":`, http.StatusBadRequest, ""},
+		{"POST", "/order", `{"subtotal":"1.00"}`, http.StatusBadRequest, "must specify user"},
+		{"POST", "/order", `{"customer":{"id":1},"products":[{}]}`, http.StatusBadRequest, "must specify a product ID"},
+		{"POST", "/order", `{"customer":{"id":2}}`, http.StatusUnprocessableEntity, "customer 2 does not exist"},
+		{"POST", "/order", `{"customer":{"id":1}}`, http.StatusOK, `"products":[]`},
+		{"POST", "/order/1/product", "", http.StatusBadRequest, `missing query param \"productID\"`},
+		{"POST", "/order/1/product?productID=x", "", http.StatusBadRequest, `invalid query param \"productID\"`},
+	}
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if w.Code != tc.code {
+			t.Errorf("%s %s: expected status %d, found %d: %s", tc.method, tc.path, tc.code, w.Code, w.Body)
+			continue
+		}
+		if body := w.Body.String(); !strings.Contains(body, tc.expected) {
+			t.Errorf("%s %s: expected body containing %q, found %q", tc.method, tc.path, tc.expected, body)
+		}
+		if w.Code != http.StatusOK {
+			var p Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Status != tc.code {
+				t.Errorf("%s %s: expected a problem with status %d, found %q", tc.method, tc.path, tc.code, w.Body)
+			}
+		}
+	}
+	if store.updatedID != 7 {
+		t.Errorf("expected the customer to be updated by the ID in the path, found ID %d", store.updatedID)
+	}
+}
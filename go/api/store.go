@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Customer is a customer as exchanged with clients.
+type Customer struct {
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// Product is a product as exchanged with clients.
+type Product struct {
+	ID    int     `json:"id,omitempty"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price,string"`
+}
+
+// Order is an order as exchanged with clients, along with the customer who
+// placed it and the products it holds.
+type Order struct {
+	ID       int       `json:"id,omitempty"`
+	Subtotal float64   `json:"subtotal,string"`
+	Customer Customer  `json:"customer"`
+	Products []Product `json:"products"`
+}
+
+// Store is the storage behind a Server. Each Go sample application
+// implements it with its ORM.
+//
+// Methods that take the ID of a row return ErrNotFound if there is no such
+// row. Methods that create or update a row that refers to other rows, such as
+// the customer and products of an order, return a *ReferenceError if one of
+// them does not exist. Orders are always returned with their customer and
+// products. All other errors are classified by StatusCode, so they should
+// carry their SQLSTATE code where there is one.
+type Store interface {
+	ListCustomers(ctx context.Context, lq *ListQuery) ([]Customer, error)
+	GetCustomer(ctx context.Context, id int) (Customer, error)
+	CreateCustomer(ctx context.Context, c Customer) (Customer, error)
+	// UpdateCustomer replaces the customer with the given ID by c, ignoring
+	// c.ID.
+	UpdateCustomer(ctx context.Context, id int, c Customer) (Customer, error)
+	DeleteCustomer(ctx context.Context, id int) error
+
+	ListProducts(ctx context.Context, lq *ListQuery) ([]Product, error)
+	GetProduct(ctx context.Context, id int) (Product, error)
+	CreateProduct(ctx context.Context, p Product) (Product, error)
+	// UpdateProduct replaces the product with the given ID by p, ignoring
+	// p.ID.
+	UpdateProduct(ctx context.Context, id int, p Product) (Product, error)
+	DeleteProduct(ctx context.Context, id int) error
+
+	ListOrders(ctx context.Context, lq *ListQuery) ([]Order, error)
+	GetOrder(ctx context.Context, id int) (Order, error)
+	// CreateOrder creates an order for the customer and products of o, of
+	// which only the IDs are used. An order has each product at most once, so
+	// products that o lists more than once are only added once.
+	CreateOrder(ctx context.Context, o Order) (Order, error)
+	// UpdateOrder sets the subtotal and customer of the order with the given
+	// ID to those of o. The products of the order are replaced by those of o
+	// unless o.Products is nil.
+	UpdateOrder(ctx context.Context, id int, o Order) (Order, error)
+	// DeleteOrder deletes an order along with its association to its
+	// products.
+	DeleteOrder(ctx context.Context, id int) error
+	// AddProductToOrder adds a product to an order and returns the order.
+	// Adding a product that the order already has leaves the order as it is.
+	AddProductToOrder(ctx context.Context, orderID, productID int) (Order, error)
+}
+
+// ErrNotFound is returned by Store methods when the row they were asked for
+// does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ReferenceError is returned by Store methods when a row refers to another
+// row that does not exist.
+type ReferenceError struct {
+	// Entity is the kind of the row that does not exist, such as "customer".
+	Entity string
+	ID     int
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("%s %d does not exist", e.Entity, e.ID)
+}
+
+// The fields that each list endpoint may be filtered and sorted by. Columns
+// are those of the tables that the sample applications share.
+var (
+	customerFields = Fields{
+		{Name: "id", Column: "id", Type: IntField},
+		{Name: "name", Column: "name", Type: StringField},
+	}
+	productFields = Fields{
+		{Name: "id", Column: "id", Type: IntField},
+		{Name: "name", Column: "name", Type: StringField},
+		{Name: "price", Column: "price", Type: DecimalField, Nullable: true},
+	}
+	orderFields = Fields{
+		{Name: "id", Column: "id", Type: IntField},
+		{Name: "customer_id", Column: "customer_id", Type: IntField, Nullable: true},
+		{Name: "subtotal", Column: "subtotal", Type: DecimalField, Nullable: true},
+	}
+)
+
+// CustomerRow returns the values of the fields of the customers list endpoint
+// for c.
+func CustomerRow(c Customer) Row {
+	return Row{"id": c.ID, "name": c.Name}
+}
+
+// ProductRow returns the values of the fields of the products list endpoint
+// for p.
+func ProductRow(p Product) Row {
+	return Row{"id": p.ID, "name": p.Name, "price": p.Price}
+}
+
+// OrderRow returns the values of the fields of the orders list endpoint for
+// o.
+func OrderRow(o Order) Row {
+	return Row{"id": o.ID, "customer_id": o.Customer.ID, "subtotal": o.Subtotal}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The exporters that spans can be sent to.
+const (
+	// NoExporter disables tracing.
+	NoExporter = "none"
+	// StdoutExporter writes spans to stdout as JSON, one span per line.
+	StdoutExporter = "stdout"
+	// FileExporter writes spans to a file as JSON, one span per line.
+	FileExporter = "file"
+	// OTLPExporter sends spans to an OpenTelemetry collector over OTLP/HTTP.
+	// The collector is configured through the standard OTEL_EXPORTER_OTLP_*
+	// environment variables.
+	OTLPExporter = "otlp"
+)
+
+// tracerName is the name of the tracer that creates the spans of the Go
+// servers.
+const tracerName = "github.com/cockroachdb/examples-orms/go/api"
+
+// SetupTracing installs the global OpenTelemetry tracer provider of the
+// application app, which sends spans to the given exporter. path is the file
+// that spans are appended to when exporter is FileExporter. The returned
+// function flushes pending spans and must be called before exiting.
+func SetupTracing(app, exporter, path string) (shutdown func(context.Context) error, err error) {
+	var exp sdktrace.SpanExporter
+	closeFile := func() error { return nil }
+	switch exporter {
+	case NoExporter, "":
+		return func(context.Context) error { return nil }, nil
+	case StdoutExporter:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case FileExporter:
+		f, openErr := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if openErr != nil {
+			return nil, fmt.Errorf("failed to open trace file: %v", openErr)
+		}
+		closeFile = f.Close
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	case OTLPExporter:
+		exp, err = otlptracehttp.New(context.Background())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		_ = closeFile()
+		return nil, fmt.Errorf("failed to create trace exporter: %v", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(app),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closeErr := closeFile(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// Trace returns a handler that runs each request to h in a span named after
+// the route that served it; see handleRoute. The span continues the trace
+// of the client, if it propagated one.
+func Trace(h http.Handler) http.Handler {
+	tracer := otel.Tracer(tracerName)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		// The route is only known once the router has served the request,
+		// so the span is named after it then.
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(r.Method),
+				semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+			),
+		)
+		defer span.End()
+
+		rec := newResponseRecorder(w)
+		r, route := withMatchedRoute(r.WithContext(ctx))
+		h.ServeHTTP(rec, r)
+
+		span.SetName(r.Method + " " + route.String())
+		span.SetAttributes(semconv.HTTPRouteKey.String(route.String()))
+		status := rec.Status()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if id := w.Header().Get(RequestIDHeader); id != "" {
+			span.SetAttributes(attribute.String("http.request_id", id))
+		}
+	})
+}
+
+// StartSQLSpan starts the span of a SQL statement issued by the given ORM. op
+// is the kind of statement, such as "query" or "create", and table is the
+// table it operates on, if known.
+func StartSQLSpan(ctx context.Context, orm, op, table string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemCockroachdb,
+		semconv.DBOperationKey.String(op),
+		attribute.String("db.orm", orm),
+	}
+	if table != "" {
+		attrs = append(attrs, semconv.DBSQLTableKey.String(table))
+	}
+	return otel.Tracer(tracerName).Start(ctx, orm+"."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// EndSQLSpan ends the span of a SQL statement that was started by
+// StartSQLSpan, recording the statement and the error it failed with, if any.
+func EndSQLSpan(span trace.Span, statement string, rowsAffected int64, err error) {
+	span.SetAttributes(
+		semconv.DBStatementKey.String(statement),
+		attribute.Int64("db.rows_affected", rowsAffected),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if code := SQLState(err); code != "" {
+			span.SetAttributes(attribute.String("db.sqlstate", code))
+		}
+	}
+	span.End()
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTrace(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	router := httprouter.New()
+	handleRoute(router, http.MethodGet, "/customer/:customerID", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		_, span := StartSQLSpan(r.Context(), "test", "query", "customers")
+		EndSQLSpan(span, "SELECT * FROM customers WHERE id = $1", 1, nil)
+	})
+	Trace(router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/customer/customer", nil))
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, found %d", len(spans))
+	}
+	sqlSpan, reqSpan := spans[0], spans[1]
+	if name := reqSpan.Name(); name != "GET /customer/:customerID" {
+		t.Errorf("unexpected request span name %q", name)
+	}
+	if name := sqlSpan.Name(); name != "test.query" {
+		t.Errorf("unexpected statement span name %q", name)
+	}
+	if sqlSpan.Parent().SpanID() != reqSpan.SpanContext().SpanID() {
+		t.Errorf("expected the statement span to be a child of the request span")
+	}
+}
+
+func TestSetupTracingFile(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := SetupTracing("go/test", FileExporter, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := StartSQLSpan(context.Background(), "test", "query", "")
+	EndSQLSpan(span, "SELECT 1", 0, nil)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names []string
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var s struct{ Name string }
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, s.Name)
+	}
+	if len(names) != 1 || names[0] != "test.query" {
+		t.Errorf("expected a single test.query span, found %v", names)
+	}
+}
@@ -0,0 +1,77 @@
+// Package api contains the parts of the sample application's JSON REST API
+// that are shared by the Go ORM examples.
+package api
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/v2/crdb"
+)
+
+const (
+	// MaxTxnRetries bounds the number of times a transaction is retried after
+	// a retryable error before the request is failed.
+	MaxTxnRetries = 10
+	// initialTxnBackoff and maxTxnBackoff bound the exponential backoff
+	// between transaction retries.
+	initialTxnBackoff = 10 * time.Millisecond
+	maxTxnBackoff     = 500 * time.Millisecond
+)
+
+// RetriesExhaustedError is returned by RunTxn for transactions that kept
+// failing with retryable errors (SQLSTATE 40001) until they exhausted their
+// retries. It maps to a 503 with a Retry-After header.
+type RetriesExhaustedError struct {
+	// Err is the error of the last attempt.
+	Err error
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return "transaction retries exhausted: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetriesExhaustedError) Unwrap() error { return e.Err }
+
+// RunTxn runs a transaction with the given retry policy. execute adapts one
+// of the crdb package's helpers to a driver: it must run a transaction with
+// ctx, and call attempt at the start of each attempt, returning its error if
+// it fails. The crdb helper re-runs the transaction after retryable errors,
+// at most MaxTxnRetries times, and attempt waits with exponential backoff
+// before every retry. Retries are counted in m, and transactions that
+// exhaust them fail with a *RetriesExhaustedError.
+func RunTxn(ctx context.Context, m *Metrics, execute func(ctx context.Context, attempt func() error) error) error {
+	ctx = crdb.WithMaxRetries(ctx, MaxTxnRetries)
+	attempts := 0
+	err := execute(ctx, func() error {
+		if attempts > 0 {
+			m.TxnRetries.Inc()
+			select {
+			case <-time.After(txnBackoff(attempts)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		attempts++
+		return nil
+	})
+	var maxRetriesErr *crdb.MaxRetriesExceededError
+	if errors.As(err, &maxRetriesErr) {
+		m.TxnRetriesExhausted.Inc()
+		return &RetriesExhaustedError{Err: errors.Unwrap(maxRetriesErr)}
+	}
+	return err
+}
+
+// txnBackoff returns the time to wait before the given retry attempt.
+func txnBackoff(attempt int) time.Duration {
+	backoff := initialTxnBackoff << uint(attempt-1)
+	if backoff <= 0 || backoff > maxTxnBackoff {
+		backoff = maxTxnBackoff
+	}
+	// Add jitter so that contending transactions do not retry in lockstep.
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/cockroach-go/v2/crdb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeTx is a crdb.Tx whose statements succeed.
+type fakeTx struct{}
+
+func (fakeTx) Exec(context.Context, string, ...interface{}) error { return nil }
+func (fakeTx) Commit(context.Context) error                       { return nil }
+func (fakeTx) Rollback(context.Context) error                     { return nil }
+
+// retryableError is an error with the SQLSTATE of a serialization failure.
+type retryableError struct{}
+
+func (retryableError) Error() string    { return "restart transaction" }
+func (retryableError) SQLState() string { return CodeSerializationFailure }
+
+func TestRunTxn(t *testing.T) {
+	retryable := retryableError{}
+	run := func(m *Metrics, failures int) (int, error) {
+		attempts := 0
+		err := RunTxn(context.Background(), m, func(ctx context.Context, attempt func() error) error {
+			return crdb.ExecuteInTx(ctx, fakeTx{}, func() error {
+				if err := attempt(); err != nil {
+					return err
+				}
+				attempts++
+				if attempts <= failures {
+					return retryable
+				}
+				return nil
+			})
+		})
+		return attempts, err
+	}
+
+	m := NewMetrics()
+	if attempts, err := run(m, 2); err != nil || attempts != 3 {
+		t.Fatalf("expected success after 3 attempts, found %d attempts and error %v", attempts, err)
+	}
+	if retries := testutil.ToFloat64(m.TxnRetries); retries != 2 {
+		t.Errorf("expected 2 retries, found %v", retries)
+	}
+
+	m = NewMetrics()
+	_, err := run(m, MaxTxnRetries+1)
+	var retriesErr *RetriesExhaustedError
+	if !errors.As(err, &retriesErr) || !errors.Is(err, retryable) {
+		t.Fatalf("expected a RetriesExhaustedError wrapping %v, found %v", retryable, err)
+	}
+	if code := StatusCode(err); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, found %d", http.StatusServiceUnavailable, code)
+	}
+	if exhausted := testutil.ToFloat64(m.TxnRetriesExhausted); exhausted != 1 {
+		t.Errorf("expected 1 exhausted transaction, found %v", exhausted)
+	}
+}
@@ -0,0 +1,514 @@
+// Package client is a typed Go client for the JSON REST API implemented by
+// each of the sample applications.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	jsonContentType = "application/json"
+
+	// DefaultRetryBackoff is the time waited before retrying a request that
+	// failed with a 503, unless the application asks for a different delay
+	// with a Retry-After header.
+	DefaultRetryBackoff = time.Second
+)
+
+// Client talks to a sample application. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+
+	maxRetries    int
+	retryBackoff  time.Duration
+	trailingSlash bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient makes the Client send its requests with c instead of
+// http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = c
+	}
+}
+
+// WithRetry makes the Client retry requests that fail with a 503 Service
+// Unavailable, which the Go applications return when a transaction could not
+// be completed due to contention, at most maxRetries times. GET, PUT and
+// DELETE requests, which are idempotent, are retried after any 503. Other
+// requests might have taken effect before failing, so they are only retried
+// after the 503s of contention errors, which come as problem details with a
+// Retry-After header and whose transaction did not commit. Between attempts,
+// the Client waits for the delay given by the response's Retry-After header,
+// or for backoff if it has none.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(client *Client) {
+		client.maxRetries = maxRetries
+		client.retryBackoff = backoff
+	}
+}
+
+// WithTrailingSlash makes the Client request the routes of the API with a
+// trailing slash, such as /customer/ and /customer/1/, which is the only form
+// that some of the applications route. The Go applications redirect these
+// paths to the ones without a trailing slash.
+func WithTrailingSlash() Option {
+	return func(client *Client) {
+		client.trailingSlash = true
+	}
+}
+
+// New creates a Client for the application served at baseURL, such as
+// http://localhost:6543.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base URL %q must be absolute", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c := &Client{
+		baseURL:      u,
+		httpClient:   http.DefaultClient,
+		retryBackoff: DefaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// BaseURL returns the URL of the application the Client talks to.
+func (c *Client) BaseURL() string {
+	return c.baseURL.String()
+}
+
+// Customer is a customer of the sample application.
+type Customer struct {
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// Product is a product of the sample application. Product names are unique.
+type Product struct {
+	ID    int     `json:"id,omitempty"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price,string"`
+}
+
+// Order is an order that a Customer placed for a set of Products.
+type Order struct {
+	ID       int     `json:"id,omitempty"`
+	Subtotal float64 `json:"subtotal,string"`
+
+	Customer Customer  `json:"customer"`
+	Products []Product `json:"products"`
+}
+
+// Readiness is the body of a response to /readyz.
+type Readiness struct {
+	Status string            `json:"status"`
+	App    string            `json:"app"`
+	Checks map[string]string `json:"checks"`
+}
+
+// ListOptions select the page of a list endpoint's results to return. The
+// zero value selects the first page of the unfiltered results, in the
+// application's default order. The Go applications return pages of 100 rows
+// unless a Limit is given.
+type ListOptions struct {
+	// Limit is the maximum number of rows of the page, which the Go
+	// applications allow up to 1000.
+	Limit int
+	// After is the cursor of the page, taken from the previous page.
+	After string
+	// Sort is a comma-separated list of the fields to sort by, each prefixed
+	// with '-' to sort in descending order.
+	Sort string
+	// Filters are the filters that the rows must match, such as
+	// "name.prefix" or "price.gte", mapped to their values.
+	Filters url.Values
+}
+
+func (o *ListOptions) query() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	for k, vs := range o.Filters {
+		q[k] = append([]string(nil), vs...)
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.After != "" {
+		q.Set("after", o.After)
+	}
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+	return q
+}
+
+// Ping returns the name of the application.
+func (c *Client) Ping(ctx context.Context) (string, error) {
+	var b bytes.Buffer
+	if _, err := c.do(ctx, http.MethodGet, c.route("/ping"), nil, nil, &b); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Ready returns whether the application is ready to serve requests. An
+// application that is not ready results in an *Error with status 503 that
+// describes it in its Readiness.
+func (c *Client) Ready(ctx context.Context) (*Readiness, error) {
+	var r Readiness
+	if err := c.doJSON(ctx, http.MethodGet, "/readyz", nil, nil, &r); err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable {
+			_ = json.Unmarshal(apiErr.Body, &r)
+			apiErr.Readiness = &r
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+// OpenAPISpec returns the OpenAPI document that the application serves.
+func (c *Client) OpenAPISpec(ctx context.Context) ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil, &b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// ListCustomers returns every customer that matches opts, following the
+// application's pagination until the last page.
+func (c *Client) ListCustomers(ctx context.Context, opts *ListOptions) ([]Customer, error) {
+	var all []Customer
+	err := c.listAll(ctx, "/customer", opts, func() interface{} {
+		return &[]Customer{}
+	}, func(page interface{}) {
+		all = append(all, *page.(*[]Customer)...)
+	})
+	return all, err
+}
+
+// ListCustomersPage returns a single page of the customers that match opts,
+// along with the cursor of the next page, which is empty on the last page.
+func (c *Client) ListCustomersPage(ctx context.Context, opts *ListOptions) ([]Customer, string, error) {
+	var page []Customer
+	next, err := c.listPage(ctx, "/customer", opts, &page)
+	return page, next, err
+}
+
+// GetCustomer returns the customer with the given ID.
+func (c *Client) GetCustomer(ctx context.Context, id int) (*Customer, error) {
+	var res Customer
+	if err := c.doJSON(ctx, http.MethodGet, c.route(idPath("/customer", id)), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateCustomer creates a customer and returns it.
+func (c *Client) CreateCustomer(ctx context.Context, customer Customer) (*Customer, error) {
+	var res Customer
+	if err := c.doJSON(ctx, http.MethodPost, c.route("/customer"), nil, customer, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateCustomer replaces the customer with the given ID and returns it.
+func (c *Client) UpdateCustomer(ctx context.Context, id int, customer Customer) (*Customer, error) {
+	var res Customer
+	if err := c.doJSON(ctx, http.MethodPut, c.route(idPath("/customer", id)), nil, customer, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteCustomer deletes the customer with the given ID.
+func (c *Client) DeleteCustomer(ctx context.Context, id int) error {
+	_, err := c.do(ctx, http.MethodDelete, c.route(idPath("/customer", id)), nil, nil, ioutil.Discard)
+	return err
+}
+
+// ListProducts returns every product that matches opts, following the
+// application's pagination until the last page.
+func (c *Client) ListProducts(ctx context.Context, opts *ListOptions) ([]Product, error) {
+	var all []Product
+	err := c.listAll(ctx, "/product", opts, func() interface{} {
+		return &[]Product{}
+	}, func(page interface{}) {
+		all = append(all, *page.(*[]Product)...)
+	})
+	return all, err
+}
+
+// ListProductsPage returns a single page of the products that match opts,
+// along with the cursor of the next page, which is empty on the last page.
+func (c *Client) ListProductsPage(ctx context.Context, opts *ListOptions) ([]Product, string, error) {
+	var page []Product
+	next, err := c.listPage(ctx, "/product", opts, &page)
+	return page, next, err
+}
+
+// GetProduct returns the product with the given ID.
+func (c *Client) GetProduct(ctx context.Context, id int) (*Product, error) {
+	var res Product
+	if err := c.doJSON(ctx, http.MethodGet, c.route(idPath("/product", id)), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateProduct creates a product and returns it.
+func (c *Client) CreateProduct(ctx context.Context, product Product) (*Product, error) {
+	var res Product
+	if err := c.doJSON(ctx, http.MethodPost, c.route("/product"), nil, product, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateProduct replaces the product with the given ID and returns it.
+func (c *Client) UpdateProduct(ctx context.Context, id int, product Product) (*Product, error) {
+	var res Product
+	if err := c.doJSON(ctx, http.MethodPut, c.route(idPath("/product", id)), nil, product, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteProduct deletes the product with the given ID.
+func (c *Client) DeleteProduct(ctx context.Context, id int) error {
+	_, err := c.do(ctx, http.MethodDelete, c.route(idPath("/product", id)), nil, nil, ioutil.Discard)
+	return err
+}
+
+// ListOrders returns every order that matches opts, following the
+// application's pagination until the last page.
+func (c *Client) ListOrders(ctx context.Context, opts *ListOptions) ([]Order, error) {
+	var all []Order
+	err := c.listAll(ctx, "/order", opts, func() interface{} {
+		return &[]Order{}
+	}, func(page interface{}) {
+		all = append(all, *page.(*[]Order)...)
+	})
+	return all, err
+}
+
+// ListOrdersPage returns a single page of the orders that match opts, along
+// with the cursor of the next page, which is empty on the last page.
+func (c *Client) ListOrdersPage(ctx context.Context, opts *ListOptions) ([]Order, string, error) {
+	var page []Order
+	next, err := c.listPage(ctx, "/order", opts, &page)
+	return page, next, err
+}
+
+// GetOrder returns the order with the given ID.
+func (c *Client) GetOrder(ctx context.Context, id int) (*Order, error) {
+	var res Order
+	if err := c.doJSON(ctx, http.MethodGet, c.route(idPath("/order", id)), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateOrder creates an order and returns it. Only the IDs of the order's
+// Customer and Products are used, and they must exist.
+func (c *Client) CreateOrder(ctx context.Context, order Order) (*Order, error) {
+	var res Order
+	if err := c.doJSON(ctx, http.MethodPost, c.route("/order"), nil, order, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateOrder replaces the order with the given ID and returns it.
+func (c *Client) UpdateOrder(ctx context.Context, id int, order Order) (*Order, error) {
+	var res Order
+	if err := c.doJSON(ctx, http.MethodPut, c.route(idPath("/order", id)), nil, order, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteOrder deletes the order with the given ID.
+func (c *Client) DeleteOrder(ctx context.Context, id int) error {
+	_, err := c.do(ctx, http.MethodDelete, c.route(idPath("/order", id)), nil, nil, ioutil.Discard)
+	return err
+}
+
+// AddProductToOrder adds the product with ID productID to the order with ID
+// orderID and returns the order.
+func (c *Client) AddProductToOrder(ctx context.Context, orderID, productID int) (*Order, error) {
+	var res Order
+	q := url.Values{"productID": []string{strconv.Itoa(productID)}}
+	if err := c.doJSON(ctx, http.MethodPost, c.route(idPath("/order", orderID)+"/product"), q, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// route returns the path of a route of the API, with a trailing slash if the
+// Client was configured to add one.
+func (c *Client) route(path string) string {
+	if c.trailingSlash {
+		return path + "/"
+	}
+	return path
+}
+
+func idPath(collection string, id int) string {
+	return collection + "/" + strconv.Itoa(id)
+}
+
+// listAll retrieves every page of the list endpoint at path, decoding each
+// into a value returned by newPage and passing it to add.
+func (c *Client) listAll(
+	ctx context.Context, path string, opts *ListOptions, newPage func() interface{}, add func(interface{}),
+) error {
+	pageOpts := ListOptions{}
+	if opts != nil {
+		pageOpts = *opts
+	}
+	for {
+		page := newPage()
+		next, err := c.listPage(ctx, path, &pageOpts, page)
+		if err != nil {
+			return err
+		}
+		add(page)
+		if next == "" {
+			return nil
+		}
+		pageOpts.After = next
+	}
+}
+
+// listPage retrieves a single page of the list endpoint at path into page,
+// and returns the cursor of the next page.
+func (c *Client) listPage(ctx context.Context, path string, opts *ListOptions, page interface{}) (string, error) {
+	var b bytes.Buffer
+	path = c.route(path)
+	resp, err := c.do(ctx, http.MethodGet, path, opts.query(), nil, &b)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(b.Bytes(), page); err != nil {
+		return "", fmt.Errorf("GET %s: decoding response: %v", path, err)
+	}
+	return resp.Header.Get("X-Next-Cursor"), nil
+}
+
+// doJSON sends a request with body encoded as JSON, if it is not nil, and
+// decodes the JSON response into res.
+func (c *Client) doJSON(
+	ctx context.Context, method, path string, query url.Values, body, res interface{},
+) error {
+	var b bytes.Buffer
+	if _, err := c.do(ctx, method, path, query, body, &b); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b.Bytes(), res); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %v", method, path, err)
+	}
+	return nil
+}
+
+// do sends a request, retrying it if it fails with a 503 that allows it and
+// the Client was configured to, and copies the body of the successful response to w.
+// Unsuccessful responses result in an *Error.
+func (c *Client) do(
+	ctx context.Context, method, path string, query url.Values, body interface{}, w io.Writer,
+) (*http.Response, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", jsonContentType)
+		}
+		req.Header.Set("Accept", jsonContentType)
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			_, err := w.Write(respBody)
+			return resp, err
+		}
+
+		apiErr := newError(req, resp, respBody)
+		if attempt >= c.maxRetries || !retryable(apiErr, resp) {
+			return nil, apiErr
+		}
+		select {
+		case <-time.After(c.retryDelay(resp)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryable reports whether the request that resulted in resp, which failed
+// with err, may be retried; see WithRetry.
+func retryable(err *Error, resp *http.Response) bool {
+	if err.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+	switch err.Method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return err.Problem != nil && resp.Header.Get("Retry-After") != ""
+	}
+}
+
+// retryDelay returns the time to wait before retrying the request that
+// resulted in resp.
+func (c *Client) retryDelay(resp *http.Response) time.Duration {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return c.retryBackoff
+}
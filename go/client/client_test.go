@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc, opts ...Option) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestListFollowsPages(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/product" || r.URL.Query().Get("sort") != "-price" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		switch after := r.URL.Query().Get("after"); after {
+		case "":
+			w.Header().Set("X-Next-Cursor", "c1")
+			fmt.Fprint(w, `[{"id": 1, "name": "a", "price": "2.50"}]`)
+		case "c1":
+			fmt.Fprint(w, `[{"id": 2, "name": "b", "price": "1"}]`)
+		default:
+			t.Errorf("unexpected cursor %q", after)
+		}
+	})
+
+	found, err := c.ListProducts(context.Background(), &ListOptions{Sort: "-price"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Product{{ID: 1, Name: "a", Price: 2.5}, {ID: 2, Name: "b", Price: 1}}
+	if !reflect.DeepEqual(expected, found) {
+		t.Errorf("expected %v, found %v", expected, found)
+	}
+}
+
+func TestError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/customer/7" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Header().Set("Content-Type", problemContentType)
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"type": "about:blank", "title": "Not Found", "status": 404, "request_id": "abc"}`)
+	})
+
+	customer, err := c.UpdateCustomer(context.Background(), 7, Customer{Name: "x"})
+	if !IsNotFound(err) {
+		t.Fatalf("expected a not found error, found %v", err)
+	}
+	if customer != nil {
+		t.Errorf("expected no customer with the error, found %+v", customer)
+	}
+	if p := err.(*Error).Problem; p == nil || p.RequestID != "abc" {
+		t.Errorf("expected problem details with the request ID, found %+v", p)
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	var paths []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/ping/":
+			fmt.Fprint(w, "python")
+		case "/order/":
+			fmt.Fprint(w, `[]`)
+		default:
+			fmt.Fprint(w, `{"id": 1}`)
+		}
+	}, WithTrailingSlash())
+
+	ctx := context.Background()
+	if _, err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListOrders(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetCustomer(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddProductToOrder(ctx, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"/ping/", "/order/", "/customer/1/", "/order/1/product/"}
+	if !reflect.DeepEqual(expected, paths) {
+		t.Errorf("expected paths %v, found %v", expected, paths)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, maxRetries := range []int{0, 2} {
+		attempts := 0
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts <= 2 {
+				w.Header().Set("Content-Type", problemContentType)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, `{"title": "Service Unavailable", "status": 503}`)
+				return
+			}
+			fmt.Fprint(w, `{"id": 3, "name": "c"}`)
+		}, WithRetry(maxRetries, time.Millisecond))
+
+		customer, err := c.CreateCustomer(context.Background(), Customer{Name: "c"})
+		if maxRetries == 0 {
+			if StatusCode(err) != http.StatusServiceUnavailable || attempts != 1 {
+				t.Errorf("expected a single failed attempt, found %d attempts and %v", attempts, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if customer.ID != 3 || attempts != 3 {
+			t.Errorf("expected customer 3 after 3 attempts, found %+v after %d", customer, attempts)
+		}
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	// A 503 that is not about contention might come after the request took
+	// effect, so only idempotent requests are retried after it.
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"id": 3, "name": "c"}`)
+	}, WithRetry(2, time.Millisecond))
+
+	if _, err := c.CreateCustomer(context.Background(), Customer{Name: "c"}); StatusCode(err) != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("expected a single failed POST, found %d attempts and %v", attempts, err)
+	}
+	attempts = 0
+	if _, err := c.GetCustomer(context.Background(), 3); err != nil || attempts != 2 {
+		t.Errorf("expected a GET to succeed after 2 attempts, found %d attempts and %v", attempts, err)
+	}
+}
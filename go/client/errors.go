@@ -0,0 +1,91 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, which the Go applications
+// return as the body of their error responses.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	SQLState  string `json:"sqlstate,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Error is the error returned for a response with a status other than 200.
+type Error struct {
+	Method string
+	URL    string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Problem holds the problem details of the response, or nil if the
+	// application did not return any.
+	Problem *Problem
+	// Readiness describes why the application is not ready, for errors
+	// returned by Client.Ready.
+	Readiness *Readiness
+	// Body is the body of the response.
+	Body []byte
+}
+
+func newError(req *http.Request, resp *http.Response, body []byte) *Error {
+	e := &Error{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Body:       body,
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == problemContentType {
+		var p Problem
+		if err := json.Unmarshal(body, &p); err == nil {
+			e.Problem = &p
+		}
+	}
+	return e
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s: HTTP error %d", e.Method, e.URL, e.StatusCode)
+	if p := e.Problem; p != nil {
+		fmt.Fprintf(&b, ": %s", p.Title)
+		if p.Detail != "" {
+			fmt.Fprintf(&b, ": %s", p.Detail)
+		}
+		if p.SQLState != "" {
+			fmt.Fprintf(&b, " (SQLSTATE %s)", p.SQLState)
+		}
+		if p.RequestID != "" {
+			fmt.Fprintf(&b, " [request %s]", p.RequestID)
+		}
+	} else {
+		fmt.Fprintf(&b, ": %s", http.StatusText(e.StatusCode))
+	}
+	return b.String()
+}
+
+// StatusCode returns the HTTP status code of the response that caused err,
+// or 0 if err is not caused by an unsuccessful response.
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound returns whether err was caused by a 404 Not Found response.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
@@ -0,0 +1,45 @@
+package gopgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/gopg/model"
+)
+
+// ReadinessChecks returns the checks that must pass before a server backed
+// by the store is ready to serve requests.
+func (s *Store) ReadinessChecks() []api.ReadinessCheck {
+	return []api.ReadinessCheck{
+		{Name: "database", Check: s.checkDatabase},
+		{Name: "schema", Check: s.checkSchema},
+		{Name: "pool", Check: s.checkPool},
+	}
+}
+
+func (s *Store) checkDatabase(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
+func (s *Store) checkSchema(ctx context.Context) error {
+	for _, model := range []interface{}{
+		(*model.Customer)(nil),
+		(*model.Order)(nil),
+		(*model.Product)(nil),
+		(*model.OrderProduct)(nil),
+	} {
+		if _, err := s.db.ModelContext(ctx, model).Exists(); err != nil {
+			return fmt.Errorf("table for %T has not been migrated: %v", model, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) checkPool(ctx context.Context) error {
+	stats := s.db.PoolStats()
+	if size := s.db.Options().PoolSize; stats.TotalConns >= uint32(size) && stats.IdleConns == 0 {
+		return fmt.Errorf("all %d connections are in use", size)
+	}
+	return nil
+}
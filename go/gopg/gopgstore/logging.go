@@ -0,0 +1,40 @@
+package gopgstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/go-pg/pg/v10"
+)
+
+// loggingHook is a pg.QueryHook that logs SQL statements to an api.Logger, so
+// that the statements issued while serving a request are tagged with its ID.
+type loggingHook struct {
+	l *api.Logger
+}
+
+var _ pg.QueryHook = loggingHook{}
+
+// BeforeQuery is part of the pg.QueryHook interface.
+func (loggingHook) BeforeQuery(ctx context.Context, _ *pg.QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+// AfterQuery is part of the pg.QueryHook interface.
+func (h loggingHook) AfterQuery(ctx context.Context, evt *pg.QueryEvent) error {
+	err := evt.Err
+	if errors.Is(err, pg.ErrNoRows) {
+		// Not finding a row is an expected outcome, not a failure of the
+		// statement.
+		err = nil
+	}
+	statement, _ := evt.FormattedQuery()
+	var rowsAffected int64
+	if evt.Result != nil {
+		rowsAffected = int64(evt.Result.RowsAffected())
+	}
+	h.l.LogStatement(ctx, "gopg", string(statement), time.Since(evt.StartTime), rowsAffected, err)
+	return nil
+}
@@ -0,0 +1,59 @@
+package gopgstore
+
+import (
+	"github.com/go-pg/pg/v10"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector collects the statistics of a go-pg connection pool, as
+// reported by pg.DB.PoolStats.
+type poolCollector struct {
+	db *pg.DB
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+	poolSize   *prometheus.Desc
+}
+
+func newPoolCollector(db *pg.DB) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("gopg_pool_"+name, help, nil, prometheus.Labels{"db_name": AppName})
+	}
+	return &poolCollector{
+		db:         db,
+		hits:       desc("hits_total", "Number of times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times a wait for a connection timed out."),
+		totalConns: desc("connections", "Number of connections in the pool."),
+		idleConns:  desc("idle_connections", "Number of idle connections in the pool."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+		poolSize:   desc("max_connections", "Maximum number of connections in the pool."),
+	}
+}
+
+// Describe is part of the prometheus.Collector interface.
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+	ch <- c.poolSize
+}
+
+// Collect is part of the prometheus.Collector interface.
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+	ch <- prometheus.MustNewConstMetric(c.poolSize, prometheus.GaugeValue, float64(c.db.Options().PoolSize))
+}
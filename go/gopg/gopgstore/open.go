@@ -0,0 +1,48 @@
+package gopgstore
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/gopg/model"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Open connects to the database at addr and creates its tables. SQL
+// statements are traced, and logged to logger.
+func Open(addr string, logger *api.Logger) (*pg.DB, error) {
+	opt, err := pg.ParseURL(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse addr URL %s: %v", addr, err)
+	}
+	db := pg.Connect(opt)
+	db.AddQueryHook(tracingHook{})
+	db.AddQueryHook(loggingHook{logger})
+
+	// Need to register OrderProduct before creating it because Order references
+	// it.
+	orm.RegisterTable((*model.OrderProduct)(nil))
+
+	for _, model := range []interface{}{
+		(*model.Customer)(nil),
+		(*model.Order)(nil),
+		(*model.Product)(nil),
+		(*model.OrderProduct)(nil),
+	} {
+		err := db.Model(model).CreateTable(&orm.CreateTableOptions{
+			IfNotExists:   true,
+			FKConstraints: true,
+		})
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to create a table: %v", err)
+		}
+	}
+	return db, nil
+}
+
+// Close closes the connections of the Store's database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,434 @@
+package gopgstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/gopg/model"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// AppName is the name under which the server identifies itself.
+const AppName = "go/gopg"
+
+// Store is an api.Store that keeps its data in CockroachDB through go-pg.
+type Store struct {
+	db      *pg.DB
+	metrics *api.Metrics
+}
+
+// New creates a new instance of a Store over db, which records its metrics
+// in metrics. db should have been opened by Open.
+func New(db *pg.DB, metrics *api.Metrics) *Store {
+	metrics.MustRegister(newPoolCollector(db))
+	return &Store{db: db, metrics: metrics}
+}
+
+var _ api.Store = (*Store)(nil)
+
+func (s *Store) ListCustomers(ctx context.Context, lq *api.ListQuery) ([]api.Customer, error) {
+	var customers []model.Customer
+	where, args := lq.Where()
+	if err := s.db.ModelContext(ctx, &customers).Where(where, args...).OrderExpr(lq.OrderBy()).Limit(lq.FetchLimit()).Select(); err != nil {
+		return nil, err
+	}
+	res := make([]api.Customer, len(customers))
+	for i, c := range customers {
+		res[i] = customerFromModel(c)
+	}
+	return res, nil
+}
+
+func (s *Store) GetCustomer(ctx context.Context, id int) (api.Customer, error) {
+	customer := model.Customer{ID: id}
+	if err := s.db.ModelContext(ctx, &customer).WherePK().Select(); err != nil {
+		return api.Customer{}, translateError(err)
+	}
+	return customerFromModel(customer), nil
+}
+
+func (s *Store) CreateCustomer(ctx context.Context, c api.Customer) (api.Customer, error) {
+	var customer model.Customer
+	if err := s.runTxn(ctx, func(tx *pg.Tx) error {
+		customer = model.Customer{Name: c.Name}
+		_, err := tx.ModelContext(ctx, &customer).Insert()
+		return err
+	}); err != nil {
+		return api.Customer{}, err
+	}
+	return customerFromModel(customer), nil
+}
+
+func (s *Store) UpdateCustomer(ctx context.Context, id int, c api.Customer) (api.Customer, error) {
+	customer := model.Customer{ID: id, Name: c.Name}
+	if err := s.runTxn(ctx, func(tx *pg.Tx) error {
+		return updateRow(tx.ModelContext(ctx, &customer))
+	}); err != nil {
+		return api.Customer{}, err
+	}
+	return customerFromModel(customer), nil
+}
+
+func (s *Store) DeleteCustomer(ctx context.Context, id int) error {
+	return s.runTxn(ctx, func(tx *pg.Tx) error {
+		return deleteRow(tx.ModelContext(ctx, &model.Customer{ID: id}))
+	})
+}
+
+func (s *Store) ListProducts(ctx context.Context, lq *api.ListQuery) ([]api.Product, error) {
+	var products []model.Product
+	where, args := lq.Where()
+	if err := s.db.ModelContext(ctx, &products).Where(where, args...).OrderExpr(lq.OrderBy()).Limit(lq.FetchLimit()).Select(); err != nil {
+		return nil, err
+	}
+	res := make([]api.Product, len(products))
+	for i, p := range products {
+		res[i] = productFromModel(p)
+	}
+	if n, more := lq.Trim(len(res)); more {
+		if err := loadExactDecimals(ctx, s.db, lq, "products", res[n-1].ID); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) GetProduct(ctx context.Context, id int) (api.Product, error) {
+	product := model.Product{ID: id}
+	if err := s.db.ModelContext(ctx, &product).WherePK().Select(); err != nil {
+		return api.Product{}, translateError(err)
+	}
+	return productFromModel(product), nil
+}
+
+func (s *Store) CreateProduct(ctx context.Context, p api.Product) (api.Product, error) {
+	var product model.Product
+	if err := s.runTxn(ctx, func(tx *pg.Tx) error {
+		product = model.Product{Name: p.Name, Price: p.Price}
+		_, err := tx.ModelContext(ctx, &product).Insert()
+		return err
+	}); err != nil {
+		return api.Product{}, err
+	}
+	return productFromModel(product), nil
+}
+
+func (s *Store) UpdateProduct(ctx context.Context, id int, p api.Product) (api.Product, error) {
+	product := model.Product{ID: id, Name: p.Name, Price: p.Price}
+	if err := s.runTxn(ctx, func(tx *pg.Tx) error {
+		return updateRow(tx.ModelContext(ctx, &product))
+	}); err != nil {
+		return api.Product{}, err
+	}
+	return productFromModel(product), nil
+}
+
+func (s *Store) DeleteProduct(ctx context.Context, id int) error {
+	return s.runTxn(ctx, func(tx *pg.Tx) error {
+		return deleteRow(tx.ModelContext(ctx, &model.Product{ID: id}))
+	})
+}
+
+func (s *Store) ListOrders(ctx context.Context, lq *api.ListQuery) ([]api.Order, error) {
+	var orders []model.Order
+	where, args := lq.Where()
+	if err := selectOrders(s.db.ModelContext(ctx, &orders)).Where(where, args...).OrderExpr(lq.OrderBy()).Limit(lq.FetchLimit()).Select(); err != nil {
+		return nil, err
+	}
+	if err := loadCustomers(ctx, s.db, orders); err != nil {
+		return nil, err
+	}
+	res := make([]api.Order, len(orders))
+	for i, o := range orders {
+		res[i] = orderFromModel(o)
+	}
+	if n, more := lq.Trim(len(res)); more {
+		if err := loadExactDecimals(ctx, s.db, lq, "orders", res[n-1].ID); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) GetOrder(ctx context.Context, id int) (api.Order, error) {
+	order, err := getOrder(ctx, s.db, id)
+	if err != nil {
+		return api.Order{}, err
+	}
+	return orderFromModel(order), nil
+}
+
+func (s *Store) CreateOrder(ctx context.Context, o api.Order) (api.Order, error) {
+	// The existence checks and all inserts run in a single transaction so that
+	// a failure at any point leaves no trace of the order behind.
+	var order model.Order
+	if err := s.runTxn(ctx, func(tx *pg.Tx) error {
+		order = model.Order{Subtotal: o.Subtotal}
+		if err := lookupCustomer(ctx, tx, o.Customer.ID, &order.Customer); err != nil {
+			return err
+		}
+		var err error
+		if order.Products, err = lookupProducts(ctx, tx, o.Products); err != nil {
+			return err
+		}
+		order.CustomerID = order.Customer.ID
+		if _, err := tx.ModelContext(ctx, &order).Insert(); err != nil {
+			return err
+		}
+		return insertOrderProducts(ctx, tx, order.ID, order.Products)
+	}); err != nil {
+		return api.Order{}, err
+	}
+	return orderFromModel(order), nil
+}
+
+func (s *Store) UpdateOrder(ctx context.Context, id int, o api.Order) (api.Order, error) {
+	var order model.Order
+	if err := s.runTxn(ctx, func(tx *pg.Tx) error {
+		var customer model.Customer
+		if err := lookupCustomer(ctx, tx, o.Customer.ID, &customer); err != nil {
+			return err
+		}
+		update := model.Order{ID: id, Subtotal: o.Subtotal, CustomerID: customer.ID}
+		if err := updateRow(tx.ModelContext(ctx, &update).Column("subtotal", "customer_id")); err != nil {
+			return err
+		}
+		if o.Products != nil {
+			products, err := lookupProducts(ctx, tx, o.Products)
+			if err != nil {
+				return err
+			}
+			if err := deleteOrderProducts(ctx, tx, id); err != nil {
+				return err
+			}
+			if err := insertOrderProducts(ctx, tx, id, products); err != nil {
+				return err
+			}
+		}
+		var err error
+		order, err = getOrder(ctx, tx, id)
+		return err
+	}); err != nil {
+		return api.Order{}, err
+	}
+	return orderFromModel(order), nil
+}
+
+func (s *Store) DeleteOrder(ctx context.Context, id int) error {
+	return s.runTxn(ctx, func(tx *pg.Tx) error {
+		if err := deleteOrderProducts(ctx, tx, id); err != nil {
+			return err
+		}
+		return deleteRow(tx.ModelContext(ctx, &model.Order{ID: id}))
+	})
+}
+
+func (s *Store) AddProductToOrder(ctx context.Context, orderID, productID int) (api.Order, error) {
+	var order model.Order
+	if err := s.runTxn(ctx, func(tx *pg.Tx) error {
+		if err := tx.ModelContext(ctx, &model.Order{ID: orderID}).WherePK().Select(); err != nil {
+			return translateError(err)
+		}
+		var product model.Product
+		if err := lookupProduct(ctx, tx, productID, &product); err != nil {
+			return err
+		}
+		// The order_products table has no key on the order and product, so
+		// a product that the order already has is not added again.
+		exists, err := tx.ModelContext(ctx, (*model.OrderProduct)(nil)).
+			Where("order_id = ? AND product_id = ?", orderID, productID).Exists()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := insertOrderProducts(ctx, tx, orderID, []model.Product{product}); err != nil {
+				return err
+			}
+		}
+		order, err = getOrder(ctx, tx, orderID)
+		return err
+	}); err != nil {
+		return api.Order{}, err
+	}
+	return orderFromModel(order), nil
+}
+
+// selectOrders loads the products of the orders selected by q. Their
+// customers are loaded by loadCustomers, rather than joined in, so that the
+// columns of the orders table can be referred to without qualification.
+func selectOrders(q *orm.Query) *orm.Query {
+	return q.Relation("Products", func(q *orm.Query) (*orm.Query, error) {
+		return q.OrderExpr("product.id"), nil
+	})
+}
+
+// loadCustomers loads the customers of orders.
+func loadCustomers(ctx context.Context, db orm.DB, orders []model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	ids := make([]int, len(orders))
+	for i, o := range orders {
+		ids[i] = o.CustomerID
+	}
+	var customers []model.Customer
+	if err := db.ModelContext(ctx, &customers).Where("id IN (?)", pg.In(ids)).Select(); err != nil {
+		return err
+	}
+	byID := make(map[int]model.Customer, len(customers))
+	for _, c := range customers {
+		byID[c.ID] = c
+	}
+	for i := range orders {
+		orders[i].Customer = byID[orders[i].CustomerID]
+	}
+	return nil
+}
+
+// loadExactDecimals passes the decimals that the page of lq is sorted by, in
+// the row of table with the given ID, to lq as the database stores them,
+// since the models hold them as float64s.
+func loadExactDecimals(ctx context.Context, db orm.DB, lq *api.ListQuery, table string, id int) error {
+	query, n := lq.ExactDecimalsQuery(table)
+	if query == "" {
+		return nil
+	}
+	values := make([]string, n)
+	dest := make([]interface{}, n)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if _, err := db.QueryOneContext(ctx, pg.Scan(dest...), query, id); err != nil {
+		return err
+	}
+	lq.SetExactDecimals(values)
+	return nil
+}
+
+func getOrder(ctx context.Context, db orm.DB, id int) (model.Order, error) {
+	order := model.Order{ID: id}
+	if err := selectOrders(db.ModelContext(ctx, &order)).WherePK().Select(); err != nil {
+		return model.Order{}, translateError(err)
+	}
+	orders := []model.Order{order}
+	if err := loadCustomers(ctx, db, orders); err != nil {
+		return model.Order{}, err
+	}
+	return orders[0], nil
+}
+
+// lookupCustomer loads the customer that an order refers to.
+func lookupCustomer(ctx context.Context, tx *pg.Tx, id int, customer *model.Customer) error {
+	customer.ID = id
+	if err := tx.ModelContext(ctx, customer).WherePK().Select(); err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return &api.ReferenceError{Entity: "customer", ID: id}
+		}
+		return err
+	}
+	return nil
+}
+
+// lookupProduct loads a product that an order refers to.
+func lookupProduct(ctx context.Context, tx *pg.Tx, id int, product *model.Product) error {
+	product.ID = id
+	if err := tx.ModelContext(ctx, product).WherePK().Select(); err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return &api.ReferenceError{Entity: "product", ID: id}
+		}
+		return err
+	}
+	return nil
+}
+
+// lookupProducts loads the products that an order refers to, leaving out
+// duplicates, since an order has each product at most once.
+func lookupProducts(ctx context.Context, tx *pg.Tx, products []api.Product) ([]model.Product, error) {
+	res := make([]model.Product, 0, len(products))
+	seen := make(map[int]bool, len(products))
+	for _, p := range products {
+		if seen[p.ID] {
+			continue
+		}
+		seen[p.ID] = true
+		var product model.Product
+		if err := lookupProduct(ctx, tx, p.ID, &product); err != nil {
+			return nil, err
+		}
+		res = append(res, product)
+	}
+	return res, nil
+}
+
+func insertOrderProducts(ctx context.Context, tx *pg.Tx, orderID int, products []model.Product) error {
+	for _, product := range products {
+		orderProduct := model.OrderProduct{OrderID: orderID, ProductID: product.ID}
+		if _, err := tx.ModelContext(ctx, &orderProduct).Insert(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteOrderProducts(ctx context.Context, tx *pg.Tx, orderID int) error {
+	_, err := tx.ModelContext(ctx, (*model.OrderProduct)(nil)).Where("order_id = ?", orderID).Delete()
+	return err
+}
+
+// updateRow updates the row of q's model by primary key. It returns
+// api.ErrNotFound if there is no such row.
+func updateRow(q *orm.Query) error {
+	res, err := q.WherePK().Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return api.ErrNotFound
+	}
+	return nil
+}
+
+// deleteRow deletes the row of q's model by primary key. It returns
+// api.ErrNotFound if there is no such row.
+func deleteRow(q *orm.Query) error {
+	res, err := q.WherePK().Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return api.ErrNotFound
+	}
+	return nil
+}
+
+// translateError translates go-pg's error for missing rows into
+// api.ErrNotFound.
+func translateError(err error) error {
+	if errors.Is(err, pg.ErrNoRows) {
+		return api.ErrNotFound
+	}
+	return err
+}
+
+func customerFromModel(c model.Customer) api.Customer {
+	return api.Customer{ID: c.ID, Name: c.Name}
+}
+
+func productFromModel(p model.Product) api.Product {
+	return api.Product{ID: p.ID, Name: p.Name, Price: p.Price}
+}
+
+func orderFromModel(o model.Order) api.Order {
+	order := api.Order{
+		ID:       o.ID,
+		Subtotal: o.Subtotal,
+		Customer: customerFromModel(o.Customer),
+		Products: make([]api.Product, len(o.Products)),
+	}
+	for i, p := range o.Products {
+		order.Products[i] = productFromModel(p)
+	}
+	return order
+}
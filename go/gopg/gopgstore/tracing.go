@@ -0,0 +1,77 @@
+package gopgstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHook is a pg.QueryHook that traces each SQL statement in a span, as a
+// child of the span of the request that issued it.
+type tracingHook struct{}
+
+var _ pg.QueryHook = tracingHook{}
+
+// spanKey is the key under which the span of a statement is stored in the
+// Stash of its pg.QueryEvent.
+type spanKey struct{}
+
+// BeforeQuery is part of the pg.QueryHook interface.
+func (tracingHook) BeforeQuery(ctx context.Context, evt *pg.QueryEvent) (context.Context, error) {
+	op, table := "query", ""
+	if q, ok := evt.Query.(orm.QueryCommand); ok {
+		op = queryOperation(q)
+		if t := q.Query().TableModel(); t != nil {
+			table = string(t.Table().SQLName)
+		}
+	}
+	ctx, span := api.StartSQLSpan(ctx, "gopg", op, table)
+	if evt.Stash == nil {
+		evt.Stash = make(map[interface{}]interface{})
+	}
+	evt.Stash[spanKey{}] = span
+	return ctx, nil
+}
+
+// AfterQuery is part of the pg.QueryHook interface.
+func (tracingHook) AfterQuery(ctx context.Context, evt *pg.QueryEvent) error {
+	span, ok := evt.Stash[spanKey{}].(trace.Span)
+	if !ok {
+		return nil
+	}
+	statement, _ := evt.UnformattedQuery()
+	var rowsAffected int64
+	if evt.Result != nil {
+		rowsAffected = int64(evt.Result.RowsAffected())
+	}
+	err := evt.Err
+	if errors.Is(err, pg.ErrNoRows) {
+		// Not finding a row is an expected outcome, not a failure of the
+		// statement.
+		err = nil
+	}
+	api.EndSQLSpan(span, string(statement), rowsAffected, wrapPGError(err))
+	return nil
+}
+
+// queryOperation returns the kind of statement that q issues.
+func queryOperation(q orm.QueryCommand) string {
+	switch q.(type) {
+	case *orm.SelectQuery:
+		return "select"
+	case *orm.InsertQuery:
+		return "insert"
+	case *orm.UpdateQuery:
+		return "update"
+	case *orm.DeleteQuery:
+		return "delete"
+	case *orm.CreateTableQuery:
+		return "create_table"
+	default:
+		return "query"
+	}
+}
@@ -0,0 +1,76 @@
+package gopgstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cockroachdb/cockroach-go/v2/crdb"
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/go-pg/pg/v10"
+)
+
+// runTxn runs fn inside a transaction that is retried after retryable errors
+// with the policy of api.RunTxn. fn must not have side effects outside of
+// the database other than on state it resets itself.
+func (s *Store) runTxn(ctx context.Context, fn func(tx *pg.Tx) error) error {
+	return api.RunTxn(ctx, s.metrics, func(ctx context.Context, attempt func() error) error {
+		tx, err := s.db.BeginContext(ctx)
+		if err != nil {
+			return err
+		}
+		return crdb.ExecuteInTx(ctx, gopgTxAdapter{tx}, func() error {
+			if err := attempt(); err != nil {
+				return err
+			}
+			return wrapPGError(fn(tx))
+		})
+	})
+}
+
+// gopgTxAdapter adapts a *pg.Tx to a crdb.Tx.
+type gopgTxAdapter struct {
+	tx *pg.Tx
+}
+
+var _ crdb.Tx = gopgTxAdapter{}
+
+// Exec is part of the crdb.Tx interface.
+func (a gopgTxAdapter) Exec(ctx context.Context, q string, args ...interface{}) error {
+	_, err := a.tx.ExecContext(ctx, q, args...)
+	return wrapPGError(err)
+}
+
+// Commit is part of the crdb.Tx interface.
+func (a gopgTxAdapter) Commit(ctx context.Context) error {
+	return wrapPGError(a.tx.CommitContext(ctx))
+}
+
+// Rollback is part of the crdb.Tx interface.
+func (a gopgTxAdapter) Rollback(ctx context.Context) error {
+	return wrapPGError(a.tx.RollbackContext(ctx))
+}
+
+// sqlStateError exposes the SQLSTATE of a pg.Error, which may be wrapped by
+// err, through the SQLState method that the crdb package uses to detect
+// retryable errors.
+type sqlStateError struct {
+	err  error
+	code string
+}
+
+// Error implements the error interface.
+func (e sqlStateError) Error() string { return e.err.Error() }
+
+// SQLState returns the SQLSTATE code of the error.
+func (e sqlStateError) SQLState() string { return e.code }
+
+// Unwrap returns the original error.
+func (e sqlStateError) Unwrap() error { return e.err }
+
+func wrapPGError(err error) error {
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		return sqlStateError{err: err, code: pgErr.Field('C')}
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package gopgstore
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/go-pg/pg/v10"
+)
+
+// fakePGError implements pg.Error with only a SQLSTATE.
+type fakePGError string
+
+func (e fakePGError) Error() string { return "ERROR: " + string(e) }
+func (e fakePGError) Field(field byte) string {
+	if field == 'C' {
+		return string(e)
+	}
+	return ""
+}
+func (e fakePGError) IntegrityViolation() bool { return false }
+
+var _ pg.Error = fakePGError("")
+
+func TestWrapPGError(t *testing.T) {
+	for _, err := range []error{
+		fakePGError(api.CodeSerializationFailure),
+		fmt.Errorf("inserting order: %w", fakePGError(api.CodeSerializationFailure)),
+	} {
+		wrapped := wrapPGError(err)
+		var stateErr interface{ SQLState() string }
+		if !errors.As(wrapped, &stateErr) || stateErr.SQLState() != api.CodeSerializationFailure {
+			t.Errorf("expected %v to expose SQLSTATE %s", wrapped, api.CodeSerializationFailure)
+		}
+		if wrapped.Error() != err.Error() || !errors.Is(wrapped, err) {
+			t.Errorf("expected %v to wrap %v", wrapped, err)
+		}
+	}
+	if err := errors.New("boom"); wrapPGError(err) != err {
+		t.Errorf("expected errors without a pg.Error to be returned as is")
+	}
+}
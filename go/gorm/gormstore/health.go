@@ -0,0 +1,48 @@
+package gormstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+)
+
+// ReadinessChecks returns the checks that must pass before a server backed
+// by the store is ready to serve requests.
+func (s *Store) ReadinessChecks() []api.ReadinessCheck {
+	return []api.ReadinessCheck{
+		{Name: "database", Check: s.checkDatabase},
+		{Name: "schema", Check: s.checkSchema},
+		{Name: "pool", Check: s.checkPool},
+	}
+}
+
+func (s *Store) checkDatabase(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (s *Store) checkSchema(ctx context.Context) error {
+	migrator := s.db.WithContext(ctx).Migrator()
+	for _, table := range []string{"customers", "orders", "products", "order_products"} {
+		if !migrator.HasTable(table) {
+			return fmt.Errorf("table %s has not been migrated", table)
+		}
+	}
+	return nil
+}
+
+func (s *Store) checkPool(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	stats := sqlDB.Stats()
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		return fmt.Errorf("all %d connections are in use", stats.MaxOpenConnections)
+	}
+	return nil
+}
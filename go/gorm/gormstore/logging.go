@@ -0,0 +1,66 @@
+package gormstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// gormLogger is a gorm logger that writes to an api.Logger, so that the SQL
+// statements issued while serving a request are tagged with its ID.
+type gormLogger struct {
+	l     *api.Logger
+	level logger.LogLevel
+}
+
+var _ logger.Interface = gormLogger{}
+
+func newGormLogger(l *api.Logger) gormLogger {
+	return gormLogger{l: l, level: logger.Info}
+}
+
+// LogMode is part of the logger.Interface interface.
+func (g gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	g.level = level
+	return g
+}
+
+// Info is part of the logger.Interface interface.
+func (g gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Info {
+		g.l.Infof(ctx, msg, args...)
+	}
+}
+
+// Warn is part of the logger.Interface interface.
+func (g gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Warn {
+		g.l.Warnf(ctx, msg, args...)
+	}
+}
+
+// Error is part of the logger.Interface interface.
+func (g gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Error {
+		g.l.Errorf(ctx, msg, args...)
+	}
+}
+
+// Trace is part of the logger.Interface interface. It is called after each
+// SQL statement.
+func (g gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if g.level <= logger.Silent {
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Not finding a record is an expected outcome, not a failure of the
+		// statement.
+		err = nil
+	}
+	statement, rowsAffected := fc()
+	g.l.LogStatement(ctx, "gorm", statement, time.Since(begin), rowsAffected, err)
+}
@@ -0,0 +1,19 @@
+package gormstore
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+// newPoolCollector returns a collector of the statistics of db's connection
+// pool, as reported by sql.DB.Stats.
+func newPoolCollector(db *gorm.DB) prometheus.Collector {
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(fmt.Sprintf("failed to access connection pool: %v", err))
+	}
+	return collectors.NewDBStatsCollector(sqlDB, AppName)
+}
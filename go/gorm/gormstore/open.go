@@ -0,0 +1,39 @@
+package gormstore
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/gorm/model"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Open connects to the database at addr and migrates its schema. SQL
+// statements are traced, and logged to logger.
+func Open(addr string, logger *api.Logger) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(addr), &gorm.Config{Logger: newGormLogger(logger)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	if err := registerTracing(db); err != nil {
+		return nil, fmt.Errorf("failed to register tracing callbacks: %v", err)
+	}
+
+	// Migrate the schema
+	if err := db.AutoMigrate(&model.Customer{}, &model.Order{}, &model.Product{}); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Close closes the connections of the Store's database.
+func (s *Store) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
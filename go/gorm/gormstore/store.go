@@ -0,0 +1,404 @@
+package gormstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/gorm/model"
+	"gorm.io/gorm"
+)
+
+// AppName is the name under which the server identifies itself.
+const AppName = "go/gorm"
+
+// Store is an api.Store that keeps its data in CockroachDB through gorm.
+type Store struct {
+	db      *gorm.DB
+	metrics *api.Metrics
+}
+
+// New creates a new instance of a Store over db, which records its metrics
+// in metrics. db should have been opened by Open.
+func New(db *gorm.DB, metrics *api.Metrics) *Store {
+	metrics.MustRegister(newPoolCollector(db))
+	return &Store{db: db, metrics: metrics}
+}
+
+var _ api.Store = (*Store)(nil)
+
+func (s *Store) ListCustomers(ctx context.Context, lq *api.ListQuery) ([]api.Customer, error) {
+	var customers []model.Customer
+	where, args := lq.Where()
+	if err := s.db.WithContext(ctx).Where(where, args...).Order(lq.OrderBy()).Limit(lq.FetchLimit()).Find(&customers).Error; err != nil {
+		return nil, err
+	}
+	res := make([]api.Customer, len(customers))
+	for i, c := range customers {
+		res[i] = customerFromModel(c)
+	}
+	return res, nil
+}
+
+func (s *Store) GetCustomer(ctx context.Context, id int) (api.Customer, error) {
+	var customer model.Customer
+	if err := s.db.WithContext(ctx).First(&customer, id).Error; err != nil {
+		return api.Customer{}, translateError(err)
+	}
+	return customerFromModel(customer), nil
+}
+
+func (s *Store) CreateCustomer(ctx context.Context, c api.Customer) (api.Customer, error) {
+	var customer model.Customer
+	if err := s.runTxn(ctx, func(tx *gorm.DB) error {
+		customer = customerToModel(c)
+		customer.ID = 0
+		return tx.Create(&customer).Error
+	}); err != nil {
+		return api.Customer{}, err
+	}
+	return customerFromModel(customer), nil
+}
+
+func (s *Store) UpdateCustomer(ctx context.Context, id int, c api.Customer) (api.Customer, error) {
+	customer := customerToModel(c)
+	customer.ID = id
+	if err := s.runTxn(ctx, func(tx *gorm.DB) error {
+		return updateRow(tx.Model(&model.Customer{}), id, map[string]interface{}{"name": customer.Name})
+	}); err != nil {
+		return api.Customer{}, err
+	}
+	return customerFromModel(customer), nil
+}
+
+func (s *Store) DeleteCustomer(ctx context.Context, id int) error {
+	return s.runTxn(ctx, func(tx *gorm.DB) error {
+		return deleteRow(tx, &model.Customer{}, id)
+	})
+}
+
+func (s *Store) ListProducts(ctx context.Context, lq *api.ListQuery) ([]api.Product, error) {
+	var products []model.Product
+	where, args := lq.Where()
+	if err := s.db.WithContext(ctx).Where(where, args...).Order(lq.OrderBy()).Limit(lq.FetchLimit()).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	res := make([]api.Product, len(products))
+	for i, p := range products {
+		res[i] = productFromModel(p)
+	}
+	if n, more := lq.Trim(len(res)); more {
+		if err := loadExactDecimals(s.db.WithContext(ctx), lq, "products", res[n-1].ID); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) GetProduct(ctx context.Context, id int) (api.Product, error) {
+	var product model.Product
+	if err := s.db.WithContext(ctx).First(&product, id).Error; err != nil {
+		return api.Product{}, translateError(err)
+	}
+	return productFromModel(product), nil
+}
+
+func (s *Store) CreateProduct(ctx context.Context, p api.Product) (api.Product, error) {
+	var product model.Product
+	if err := s.runTxn(ctx, func(tx *gorm.DB) error {
+		product = productToModel(p)
+		product.ID = 0
+		return tx.Create(&product).Error
+	}); err != nil {
+		return api.Product{}, err
+	}
+	return productFromModel(product), nil
+}
+
+func (s *Store) UpdateProduct(ctx context.Context, id int, p api.Product) (api.Product, error) {
+	product := productToModel(p)
+	product.ID = id
+	if err := s.runTxn(ctx, func(tx *gorm.DB) error {
+		return updateRow(tx.Model(&model.Product{}), id, map[string]interface{}{
+			"name":  product.Name,
+			"price": product.Price,
+		})
+	}); err != nil {
+		return api.Product{}, err
+	}
+	return productFromModel(product), nil
+}
+
+func (s *Store) DeleteProduct(ctx context.Context, id int) error {
+	return s.runTxn(ctx, func(tx *gorm.DB) error {
+		return deleteRow(tx, &model.Product{}, id)
+	})
+}
+
+func (s *Store) ListOrders(ctx context.Context, lq *api.ListQuery) ([]api.Order, error) {
+	var orders []model.Order
+	where, args := lq.Where()
+	if err := preloadOrders(s.db.WithContext(ctx)).Where(where, args...).Order(lq.OrderBy()).Limit(lq.FetchLimit()).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	res := make([]api.Order, len(orders))
+	for i, o := range orders {
+		res[i] = orderFromModel(o)
+	}
+	if n, more := lq.Trim(len(res)); more {
+		if err := loadExactDecimals(s.db.WithContext(ctx), lq, "orders", res[n-1].ID); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) GetOrder(ctx context.Context, id int) (api.Order, error) {
+	order, err := getOrder(s.db.WithContext(ctx), id)
+	if err != nil {
+		return api.Order{}, err
+	}
+	return orderFromModel(order), nil
+}
+
+func (s *Store) CreateOrder(ctx context.Context, o api.Order) (api.Order, error) {
+	var order model.Order
+	if err := s.runTxn(ctx, func(tx *gorm.DB) error {
+		order = model.Order{Subtotal: o.Subtotal}
+		if err := lookupCustomer(tx, o.Customer.ID, &order.Customer); err != nil {
+			return err
+		}
+		var err error
+		if order.Products, err = lookupProducts(tx, o.Products); err != nil {
+			return err
+		}
+		order.CustomerID = order.Customer.ID
+		return tx.Omit("Customer").Create(&order).Error
+	}); err != nil {
+		return api.Order{}, err
+	}
+	return orderFromModel(order), nil
+}
+
+func (s *Store) UpdateOrder(ctx context.Context, id int, o api.Order) (api.Order, error) {
+	var order model.Order
+	if err := s.runTxn(ctx, func(tx *gorm.DB) error {
+		var customer model.Customer
+		if err := lookupCustomer(tx, o.Customer.ID, &customer); err != nil {
+			return err
+		}
+		if err := updateRow(tx.Model(&model.Order{}), id, map[string]interface{}{
+			"subtotal":    o.Subtotal,
+			"customer_id": customer.ID,
+		}); err != nil {
+			return err
+		}
+		if o.Products != nil {
+			products, err := lookupProducts(tx, o.Products)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Order{ID: id}).Association("Products").Replace(products); err != nil {
+				return err
+			}
+		}
+		var err error
+		order, err = getOrder(tx, id)
+		return err
+	}); err != nil {
+		return api.Order{}, err
+	}
+	return orderFromModel(order), nil
+}
+
+func (s *Store) DeleteOrder(ctx context.Context, id int) error {
+	return s.runTxn(ctx, func(tx *gorm.DB) error {
+		// The join table has no cascading foreign keys, so the order's
+		// products are removed from it first.
+		if err := tx.Exec("DELETE FROM order_products WHERE order_id = ?", id).Error; err != nil {
+			return err
+		}
+		return deleteRow(tx, &model.Order{}, id)
+	})
+}
+
+func (s *Store) AddProductToOrder(ctx context.Context, orderID, productID int) (api.Order, error) {
+	var order model.Order
+	if err := s.runTxn(ctx, func(tx *gorm.DB) error {
+		if err := tx.First(&model.Order{}, orderID).Error; err != nil {
+			return translateError(err)
+		}
+		var product model.Product
+		if err := lookupProduct(tx, productID, &product); err != nil {
+			return err
+		}
+		// Associations are inserted with ON CONFLICT DO NOTHING, so adding a
+		// product that the order already has leaves the order as it is.
+		if err := tx.Model(&model.Order{ID: orderID}).Association("Products").Append(&product); err != nil {
+			return err
+		}
+		var err error
+		order, err = getOrder(tx, orderID)
+		return err
+	}); err != nil {
+		return api.Order{}, err
+	}
+	return orderFromModel(order), nil
+}
+
+// preloadOrders loads the customer and products of the orders queried by db.
+func preloadOrders(db *gorm.DB) *gorm.DB {
+	return db.Preload("Customer").Preload("Products", func(db *gorm.DB) *gorm.DB {
+		return db.Order("products.id")
+	})
+}
+
+// loadExactDecimals passes the decimals that the page of lq is sorted by, in
+// the row of table with the given ID, to lq as the database stores them,
+// since the models hold them as float64s.
+func loadExactDecimals(db *gorm.DB, lq *api.ListQuery, table string, id int) error {
+	query, n := lq.ExactDecimalsQuery(table)
+	if query == "" {
+		return nil
+	}
+	values := make([]string, n)
+	dest := make([]interface{}, n)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := db.Raw(query, id).Row().Scan(dest...); err != nil {
+		return err
+	}
+	lq.SetExactDecimals(values)
+	return nil
+}
+
+func getOrder(db *gorm.DB, id int) (model.Order, error) {
+	var order model.Order
+	if err := preloadOrders(db).First(&order, id).Error; err != nil {
+		return model.Order{}, translateError(err)
+	}
+	return order, nil
+}
+
+// lookupCustomer loads the customer that an order refers to.
+func lookupCustomer(tx *gorm.DB, id int, customer *model.Customer) error {
+	if err := tx.First(customer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &api.ReferenceError{Entity: "customer", ID: id}
+		}
+		return err
+	}
+	return nil
+}
+
+// lookupProduct loads a product that an order refers to.
+func lookupProduct(tx *gorm.DB, id int, product *model.Product) error {
+	if err := tx.First(product, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &api.ReferenceError{Entity: "product", ID: id}
+		}
+		return err
+	}
+	return nil
+}
+
+// lookupProducts loads the products that an order refers to, leaving out
+// duplicates, since an order has each product at most once.
+func lookupProducts(tx *gorm.DB, products []api.Product) ([]model.Product, error) {
+	res := make([]model.Product, 0, len(products))
+	seen := make(map[int]bool, len(products))
+	for _, p := range products {
+		if seen[p.ID] {
+			continue
+		}
+		seen[p.ID] = true
+		var product model.Product
+		if err := lookupProduct(tx, p.ID, &product); err != nil {
+			return nil, err
+		}
+		res = append(res, product)
+	}
+	return res, nil
+}
+
+// updateRow sets columns of the row of db's model with the given ID. It
+// returns api.ErrNotFound if there is no such row.
+func updateRow(db *gorm.DB, id int, values map[string]interface{}) error {
+	res := db.Where("id = ?", id).Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return api.ErrNotFound
+	}
+	return nil
+}
+
+// deleteRow deletes the row of model with the given ID. It returns
+// api.ErrNotFound if there is no such row.
+func deleteRow(tx *gorm.DB, model interface{}, id int) error {
+	res := tx.Delete(model, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return api.ErrNotFound
+	}
+	return nil
+}
+
+// translateError translates gorm's error for missing rows into
+// api.ErrNotFound.
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return api.ErrNotFound
+	}
+	return err
+}
+
+// Names are stored as NULL rather than as empty strings, so that the NOT NULL
+// constraints on them reject customers and products without a name.
+
+func customerToModel(c api.Customer) model.Customer {
+	return model.Customer{ID: c.ID, Name: nullString(c.Name)}
+}
+
+func customerFromModel(c model.Customer) api.Customer {
+	return api.Customer{ID: c.ID, Name: stringValue(c.Name)}
+}
+
+func productToModel(p api.Product) model.Product {
+	return model.Product{ID: p.ID, Name: nullString(p.Name), Price: p.Price}
+}
+
+func productFromModel(p model.Product) api.Product {
+	return api.Product{ID: p.ID, Name: stringValue(p.Name), Price: p.Price}
+}
+
+func orderFromModel(o model.Order) api.Order {
+	order := api.Order{
+		ID:       o.ID,
+		Subtotal: o.Subtotal,
+		Customer: customerFromModel(o.Customer),
+		Products: make([]api.Product, len(o.Products)),
+	}
+	for i, p := range o.Products {
+		order.Products[i] = productFromModel(p)
+	}
+	return order
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,61 @@
+package gormstore
+
+import (
+	"errors"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey is the key under which the span of a statement is stored in its
+// gorm.DB instance.
+const spanKey = "tracing:span"
+
+// registerTracing registers gorm callbacks that trace each SQL statement in
+// a span, as a child of the span of the request that issued it.
+func registerTracing(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("tracing:before_create", startSpan("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", endSpan),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", startSpan("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", endSpan),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", startSpan("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", endSpan),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", startSpan("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", endSpan),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", startSpan("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", endSpan),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startSpan(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx, span := api.StartSQLSpan(db.Statement.Context, "gorm", op, db.Statement.Table)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func endSpan(db *gorm.DB) {
+	v, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Not finding a record is an expected outcome, not a failure of the
+		// statement.
+		err = nil
+	}
+	api.EndSQLSpan(span, db.Statement.SQL.String(), db.Statement.RowsAffected, err)
+}
@@ -0,0 +1,23 @@
+package gormstore
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach-go/v2/crdb/crdbgorm"
+	"github.com/cockroachdb/examples-orms/go/api"
+	"gorm.io/gorm"
+)
+
+// runTxn runs fn inside a transaction that is retried after retryable errors
+// with the policy of api.RunTxn. fn must not have side effects outside of
+// the database other than on state it resets itself.
+func (s *Store) runTxn(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return api.RunTxn(ctx, s.metrics, func(ctx context.Context, attempt func() error) error {
+		return crdbgorm.ExecuteTx(ctx, s.db, nil, func(tx *gorm.DB) error {
+			if err := attempt(); err != nil {
+				return err
+			}
+			return fn(tx)
+		})
+	})
+}
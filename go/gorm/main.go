@@ -1,44 +1,67 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
-	"github.com/cockroachdb/examples-orms/go/gorm/model"
-	"github.com/julienschmidt/httprouter"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/gorm/gormstore"
 )
 
 var (
 	addr = flag.String("addr", "postgresql://root@localhost:26257/company_gorm?sslmode=disable", "the address of the database")
+
+	listen          = flag.String("listen", ":6543", "the address to listen on for HTTP requests")
+	requestTimeout  = flag.Duration("request-timeout", 30*time.Second, "the maximum duration of a request, including its database statements (0 disables the limit)")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "how long to wait for in-flight requests to complete when shutting down")
+
+	slowQueryThreshold = flag.Duration("slow-query-threshold", 200*time.Millisecond, "the duration above which SQL statements are logged as slow (0 disables slow query logging)")
+	logStatements      = flag.Bool("log-statements", false, "log every SQL statement, not only the slow and failed ones")
+
+	traceExporter = flag.String("trace-exporter", api.NoExporter, "where to export traces: none, stdout, file or otlp")
+	traceFile     = flag.String("trace-file", "traces.json", "the file that traces are appended to with -trace-exporter=file")
 )
 
 func main() {
 	flag.Parse()
 
-	db := setupDB(*addr)
+	shutdownTracing, err := api.SetupTracing(gormstore.AppName, *traceExporter, *traceFile)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	router := httprouter.New()
+	logger := api.NewLogger(os.Stderr)
+	logger.SlowStatementThreshold = *slowQueryThreshold
+	logger.LogStatements = *logStatements
 
-	server := NewServer(db)
-	server.RegisterRouter(router)
+	db, err := gormstore.Open(*addr, logger)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	log.Fatal(http.ListenAndServe(":6543", router))
-}
+	metrics := api.NewMetrics()
+	store := gormstore.New(db, metrics)
+	server := api.NewServer(gormstore.AppName, store, metrics, store.ReadinessChecks()...)
 
-func setupDB(addr string) *gorm.DB {
-	db, err := gorm.Open(postgres.Open(addr))
-	if err != nil {
-		panic(fmt.Sprintf("failed to connect to database: %v", err))
+	srv := &http.Server{
+		Addr:    *listen,
+		Handler: server.Handler(logger, *requestTimeout),
+	}
+	if err := api.ListenAndServe(srv, *shutdownTimeout); err != nil {
+		log.Fatal(err)
 	}
 
-	// Migrate the schema
-	if err := db.AutoMigrate(&model.Customer{}, &model.Order{}, &model.Product{}); err != nil {
-		panic(err)
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("failed to flush traces: %v", err)
 	}
 
-	return db
+	if err := store.Close(); err != nil {
+		log.Printf("failed to close database: %v", err)
+	}
 }
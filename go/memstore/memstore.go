@@ -0,0 +1,400 @@
+// Package memstore is an in-memory implementation of api.Store. It has the
+// same semantics as the stores of the Go sample applications, down to the
+// SQLSTATE codes of constraint violations, so that the shared HTTP handlers
+// can be tested without a database.
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+)
+
+// Store is an api.Store that keeps its data in memory. It is safe for
+// concurrent use. The zero value is not usable; use New.
+type Store struct {
+	mu        sync.Mutex
+	customers map[int]api.Customer
+	products  map[int]api.Product
+	orders    map[int]*order
+	// lastID holds the last ID assigned in each table.
+	lastID struct {
+		customer, product, order int
+	}
+}
+
+// order is an order as it is stored, referring to its customer and products
+// by ID.
+type order struct {
+	id         int
+	subtotal   float64
+	customerID int
+	productIDs []int
+}
+
+var _ api.Store = (*Store)(nil)
+
+// New creates an empty Store.
+func New() *Store {
+	return &Store{
+		customers: make(map[int]api.Customer),
+		products:  make(map[int]api.Product),
+		orders:    make(map[int]*order),
+	}
+}
+
+// sqlError is a constraint violation, which carries the SQLSTATE code that
+// CockroachDB reports for it.
+type sqlError struct {
+	code string
+	msg  string
+}
+
+func (e *sqlError) Error() string    { return e.msg }
+func (e *sqlError) SQLState() string { return e.code }
+
+func notNullViolation(table, column string) error {
+	return &sqlError{
+		code: api.CodeNotNullViolation,
+		msg:  fmt.Sprintf("null value in column %q of table %q violates not-null constraint", column, table),
+	}
+}
+
+func uniqueViolation(constraint string) error {
+	return &sqlError{
+		code: api.CodeUniqueViolation,
+		msg:  fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+	}
+}
+
+func foreignKeyViolation(table, referencingTable string) error {
+	return &sqlError{
+		code: api.CodeForeignKeyViolation,
+		msg:  fmt.Sprintf("delete on table %q violates foreign key constraint on table %q", table, referencingTable),
+	}
+}
+
+// ListCustomers implements api.Store.
+func (s *Store) ListCustomers(_ context.Context, lq *api.ListQuery) ([]api.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []api.Customer
+	for _, c := range s.customers {
+		if lq.Match(api.CustomerRow(c)) {
+			res = append(res, c)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return lq.Compare(api.CustomerRow(res[i]), api.CustomerRow(res[j])) < 0
+	})
+	if n := lq.FetchLimit(); len(res) > n {
+		res = res[:n]
+	}
+	return res, nil
+}
+
+// GetCustomer implements api.Store.
+func (s *Store) GetCustomer(_ context.Context, id int) (api.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.customers[id]
+	if !ok {
+		return api.Customer{}, api.ErrNotFound
+	}
+	return c, nil
+}
+
+// CreateCustomer implements api.Store.
+func (s *Store) CreateCustomer(_ context.Context, c api.Customer) (api.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.Name == "" {
+		return api.Customer{}, notNullViolation("customers", "name")
+	}
+	s.lastID.customer++
+	c.ID = s.lastID.customer
+	s.customers[c.ID] = c
+	return c, nil
+}
+
+// UpdateCustomer implements api.Store.
+func (s *Store) UpdateCustomer(_ context.Context, id int, c api.Customer) (api.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.customers[id]; !ok {
+		return api.Customer{}, api.ErrNotFound
+	}
+	if c.Name == "" {
+		return api.Customer{}, notNullViolation("customers", "name")
+	}
+	c.ID = id
+	s.customers[id] = c
+	return c, nil
+}
+
+// DeleteCustomer implements api.Store.
+func (s *Store) DeleteCustomer(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.customers[id]; !ok {
+		return api.ErrNotFound
+	}
+	for _, o := range s.orders {
+		if o.customerID == id {
+			return foreignKeyViolation("customers", "orders")
+		}
+	}
+	delete(s.customers, id)
+	return nil
+}
+
+// ListProducts implements api.Store.
+func (s *Store) ListProducts(_ context.Context, lq *api.ListQuery) ([]api.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []api.Product
+	for _, p := range s.products {
+		if lq.Match(api.ProductRow(p)) {
+			res = append(res, p)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return lq.Compare(api.ProductRow(res[i]), api.ProductRow(res[j])) < 0
+	})
+	if n := lq.FetchLimit(); len(res) > n {
+		res = res[:n]
+	}
+	return res, nil
+}
+
+// GetProduct implements api.Store.
+func (s *Store) GetProduct(_ context.Context, id int) (api.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.products[id]
+	if !ok {
+		return api.Product{}, api.ErrNotFound
+	}
+	return p, nil
+}
+
+// CreateProduct implements api.Store.
+func (s *Store) CreateProduct(_ context.Context, p api.Product) (api.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkProduct(0, p); err != nil {
+		return api.Product{}, err
+	}
+	s.lastID.product++
+	p.ID = s.lastID.product
+	s.products[p.ID] = p
+	return p, nil
+}
+
+// UpdateProduct implements api.Store.
+func (s *Store) UpdateProduct(_ context.Context, id int, p api.Product) (api.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.products[id]; !ok {
+		return api.Product{}, api.ErrNotFound
+	}
+	if err := s.checkProduct(id, p); err != nil {
+		return api.Product{}, err
+	}
+	p.ID = id
+	s.products[id] = p
+	return p, nil
+}
+
+// checkProduct checks the constraints on the name of a product that is
+// stored with the given ID, or created if the ID is 0.
+func (s *Store) checkProduct(id int, p api.Product) error {
+	if p.Name == "" {
+		return notNullViolation("products", "name")
+	}
+	for _, other := range s.products {
+		if other.ID != id && other.Name == p.Name {
+			return uniqueViolation("products_name_key")
+		}
+	}
+	return nil
+}
+
+// DeleteProduct implements api.Store.
+func (s *Store) DeleteProduct(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.products[id]; !ok {
+		return api.ErrNotFound
+	}
+	for _, o := range s.orders {
+		for _, productID := range o.productIDs {
+			if productID == id {
+				return foreignKeyViolation("products", "order_products")
+			}
+		}
+	}
+	delete(s.products, id)
+	return nil
+}
+
+// ListOrders implements api.Store.
+func (s *Store) ListOrders(_ context.Context, lq *api.ListQuery) ([]api.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []api.Order
+	for _, o := range s.orders {
+		order := s.order(o)
+		if lq.Match(api.OrderRow(order)) {
+			res = append(res, order)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return lq.Compare(api.OrderRow(res[i]), api.OrderRow(res[j])) < 0
+	})
+	if n := lq.FetchLimit(); len(res) > n {
+		res = res[:n]
+	}
+	return res, nil
+}
+
+// GetOrder implements api.Store.
+func (s *Store) GetOrder(_ context.Context, id int) (api.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[id]
+	if !ok {
+		return api.Order{}, api.ErrNotFound
+	}
+	return s.order(o), nil
+}
+
+// CreateOrder implements api.Store.
+func (s *Store) CreateOrder(_ context.Context, input api.Order) (api.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkCustomer(input.Customer.ID); err != nil {
+		return api.Order{}, err
+	}
+	productIDs, err := s.checkProducts(input.Products)
+	if err != nil {
+		return api.Order{}, err
+	}
+	s.lastID.order++
+	o := &order{
+		id:         s.lastID.order,
+		subtotal:   input.Subtotal,
+		customerID: input.Customer.ID,
+		productIDs: productIDs,
+	}
+	s.orders[o.id] = o
+	return s.order(o), nil
+}
+
+// UpdateOrder implements api.Store.
+func (s *Store) UpdateOrder(_ context.Context, id int, input api.Order) (api.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkCustomer(input.Customer.ID); err != nil {
+		return api.Order{}, err
+	}
+	o, ok := s.orders[id]
+	if !ok {
+		return api.Order{}, api.ErrNotFound
+	}
+	productIDs := o.productIDs
+	if input.Products != nil {
+		var err error
+		if productIDs, err = s.checkProducts(input.Products); err != nil {
+			return api.Order{}, err
+		}
+	}
+	s.orders[id] = &order{
+		id:         id,
+		subtotal:   input.Subtotal,
+		customerID: input.Customer.ID,
+		productIDs: productIDs,
+	}
+	return s.order(s.orders[id]), nil
+}
+
+// DeleteOrder implements api.Store.
+func (s *Store) DeleteOrder(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orders[id]; !ok {
+		return api.ErrNotFound
+	}
+	delete(s.orders, id)
+	return nil
+}
+
+// AddProductToOrder implements api.Store.
+func (s *Store) AddProductToOrder(_ context.Context, orderID, productID int) (api.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[orderID]
+	if !ok {
+		return api.Order{}, api.ErrNotFound
+	}
+	if _, ok := s.products[productID]; !ok {
+		return api.Order{}, &api.ReferenceError{Entity: "product", ID: productID}
+	}
+	for _, id := range o.productIDs {
+		if id == productID {
+			return s.order(o), nil
+		}
+	}
+	// Orders are replaced rather than modified in place, so that the
+	// product IDs of an order are never shared with another one.
+	s.orders[orderID] = &order{
+		id:         o.id,
+		subtotal:   o.subtotal,
+		customerID: o.customerID,
+		productIDs: append(append([]int(nil), o.productIDs...), productID),
+	}
+	return s.order(s.orders[orderID]), nil
+}
+
+func (s *Store) checkCustomer(id int) error {
+	if _, ok := s.customers[id]; !ok {
+		return &api.ReferenceError{Entity: "customer", ID: id}
+	}
+	return nil
+}
+
+// checkProducts checks that the products of an order exist and returns their
+// IDs, leaving out duplicates.
+func (s *Store) checkProducts(products []api.Product) ([]int, error) {
+	ids := make([]int, 0, len(products))
+	seen := make(map[int]bool, len(products))
+	for _, p := range products {
+		if _, ok := s.products[p.ID]; !ok {
+			return nil, &api.ReferenceError{Entity: "product", ID: p.ID}
+		}
+		if !seen[p.ID] {
+			seen[p.ID] = true
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids, nil
+}
+
+// order returns o along with its customer and products, which are sorted by
+// ID like the stores of the sample applications sort them.
+func (s *Store) order(o *order) api.Order {
+	res := api.Order{
+		ID:       o.id,
+		Subtotal: o.subtotal,
+		Customer: s.customers[o.customerID],
+		Products: make([]api.Product, len(o.productIDs)),
+	}
+	for i, id := range o.productIDs {
+		res.Products[i] = s.products[id]
+	}
+	sort.Slice(res.Products, func(i, j int) bool { return res.Products[i].ID < res.Products[j].ID })
+	return res
+}
@@ -0,0 +1,242 @@
+package memstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/client"
+	"github.com/julienschmidt/httprouter"
+)
+
+// newTestClient serves the shared handlers over a new Store, and returns a
+// client of the server.
+func newTestClient(t *testing.T) *client.Client {
+	router := httprouter.New()
+	api.NewServer("go/memstore", New(), api.NewMetrics()).RegisterRouter(router)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	c, err := client.New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func expectStatus(t *testing.T, op string, err error, code int) {
+	t.Helper()
+	if found := client.StatusCode(err); found != code {
+		t.Errorf("%s: expected status %d, found %d (%v)", op, code, found, err)
+	}
+}
+
+func TestCustomers(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	created, err := c.CreateCustomer(ctx, client.Customer{Name: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != 1 {
+		t.Errorf("expected ID 1, found %d", created.ID)
+	}
+	updated, err := c.UpdateCustomer(ctx, created.ID, client.Customer{Name: "Bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, err := c.GetCustomer(ctx, created.ID); err != nil || !reflect.DeepEqual(found, updated) {
+		t.Errorf("expected %+v, found %+v (%v)", updated, found, err)
+	}
+
+	_, err = c.CreateCustomer(ctx, client.Customer{})
+	expectStatus(t, "create without name", err, http.StatusBadRequest)
+	_, err = c.GetCustomer(ctx, 2)
+	expectStatus(t, "get missing", err, http.StatusNotFound)
+	_, err = c.UpdateCustomer(ctx, 2, client.Customer{Name: "Carol"})
+	expectStatus(t, "update missing", err, http.StatusNotFound)
+
+	if err := c.DeleteCustomer(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, "delete twice", c.DeleteCustomer(ctx, created.ID), http.StatusNotFound)
+}
+
+func TestProducts(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	for _, p := range []client.Product{
+		{Name: "Ice Cream", Price: 2.5},
+		{Name: "Ice Pop", Price: 1},
+		{Name: "Fudge", Price: 4},
+		{Name: "Ice Cube", Price: 0.5},
+	} {
+		if _, err := c.CreateProduct(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := c.CreateProduct(ctx, client.Product{Name: "Fudge"})
+	expectStatus(t, "create duplicate", err, http.StatusConflict)
+	_, err = c.UpdateProduct(ctx, 2, client.Product{Name: "Fudge"})
+	expectStatus(t, "rename to duplicate", err, http.StatusConflict)
+	if _, err := c.UpdateProduct(ctx, 2, client.Product{Name: "Ice Pop", Price: 1.5}); err != nil {
+		t.Errorf("updating a product without renaming it: %v", err)
+	}
+
+	// Pages of one product, filtered by prefix and sorted by price.
+	opts := &client.ListOptions{
+		Limit:   1,
+		Sort:    "-price",
+		Filters: url.Values{"name.prefix": {"Ice"}, "price.gte": {"1"}},
+	}
+	products, err := c.ListProducts(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range products {
+		names = append(names, p.Name)
+	}
+	if expected := []string{"Ice Cream", "Ice Pop"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, found %v", expected, names)
+	}
+}
+
+func TestOrders(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	customer, err := c.CreateCustomer(ctx, client.Customer{Name: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fudge, err := c.CreateProduct(ctx, client.Product{Name: "Fudge", Price: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cone, err := c.CreateProduct(ctx, client.Product{Name: "Cone", Price: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.CreateOrder(ctx, client.Order{Customer: client.Customer{ID: 9}})
+	expectStatus(t, "create for missing customer", err, http.StatusUnprocessableEntity)
+	_, err = c.CreateOrder(ctx, client.Order{Customer: *customer, Products: []client.Product{{ID: 9}}})
+	expectStatus(t, "create with missing product", err, http.StatusUnprocessableEntity)
+
+	order, err := c.CreateOrder(ctx, client.Order{Subtotal: 4, Customer: client.Customer{ID: customer.ID}, Products: []client.Product{*fudge}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err = c.AddProductToOrder(ctx, order.ID, cone.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &client.Order{ID: order.ID, Subtotal: 4, Customer: *customer, Products: []client.Product{*fudge, *cone}}
+	if found, err := c.GetOrder(ctx, order.ID); err != nil || !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %+v, found %+v (%v)", expected, found, err)
+	}
+	_, err = c.AddProductToOrder(ctx, 9, cone.ID)
+	expectStatus(t, "add product to missing order", err, http.StatusNotFound)
+
+	// Rows that orders refer to cannot be deleted.
+	expectStatus(t, "delete ordered product", c.DeleteProduct(ctx, fudge.ID), http.StatusUnprocessableEntity)
+	expectStatus(t, "delete customer with orders", c.DeleteCustomer(ctx, customer.ID), http.StatusUnprocessableEntity)
+
+	// Updates keep the products of the order unless they are given.
+	order, err = c.UpdateOrder(ctx, order.ID, client.Order{Subtotal: 5, Customer: *customer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order.Products) != 2 {
+		t.Errorf("expected the order to keep its 2 products, found %+v", order.Products)
+	}
+	order, err = c.UpdateOrder(ctx, order.ID, client.Order{Subtotal: 1, Customer: *customer, Products: []client.Product{*cone}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order.Products, []client.Product{*cone}) {
+		t.Errorf("expected the products of the order to be replaced, found %+v", order.Products)
+	}
+
+	if err := c.DeleteOrder(ctx, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteProduct(ctx, fudge.ID); err != nil {
+		t.Errorf("deleting a product that is no longer ordered: %v", err)
+	}
+}
+
+// TestDuplicateProducts checks that an order has each product at most once,
+// like the orders of the stores of the sample applications.
+func TestDuplicateProducts(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	customer, err := c.CreateCustomer(ctx, client.Customer{Name: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fudge, err := c.CreateProduct(ctx, client.Product{Name: "Fudge", Price: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []client.Product{*fudge}
+
+	order, err := c.CreateOrder(ctx, client.Order{Subtotal: 8, Customer: *customer, Products: []client.Product{*fudge, *fudge}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order.Products, expected) {
+		t.Errorf("create with a duplicate product: expected products %+v, found %+v", expected, order.Products)
+	}
+	order, err = c.AddProductToOrder(ctx, order.ID, fudge.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order.Products, expected) {
+		t.Errorf("add a product twice: expected products %+v, found %+v", expected, order.Products)
+	}
+	order, err = c.UpdateOrder(ctx, order.ID, client.Order{Subtotal: 8, Customer: *customer, Products: []client.Product{*fudge, *fudge}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order.Products, expected) {
+		t.Errorf("update with a duplicate product: expected products %+v, found %+v", expected, order.Products)
+	}
+}
+
+func TestConcurrentCreates(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.CreateProduct(ctx, api.Product{Name: "Fudge"}); err != nil && api.StatusCode(err) != http.StatusConflict {
+				t.Error(err)
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.CreateCustomer(ctx, api.Customer{Name: "Alice"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(s.products) != 1 || len(s.customers) != n {
+		t.Errorf("expected 1 product and %d customers, found %d and %d", n, len(s.products), len(s.customers))
+	}
+}
@@ -18,6 +18,9 @@ public class Application {
     @Parameter(names = "-addr", description = "the address of the database")
     private String dbAddr;
 
+    @Parameter(names = "-port", description = "the port to listen on for HTTP requests")
+    private int port = 6543;
+
     public static void main(String[] args) {
         Application app = new Application();
         new JCommander(app, args);
@@ -34,7 +37,7 @@ public class Application {
     }
 
     private void initHTTPServer() {
-        URI baseUri = UriBuilder.fromUri("http://localhost/").port(6543).build();
+        URI baseUri = UriBuilder.fromUri("http://localhost/").port(port).build();
         ResourceConfig resourceConfig = new ResourceConfig(
                 PingService.class,
                 CustomerService.class,
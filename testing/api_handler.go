@@ -1,36 +1,47 @@
 package testing
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 
-	"github.com/cockroachdb/examples-orms/go/gorm/model"
+	"github.com/cockroachdb/examples-orms/go/client"
 	"github.com/pkg/errors"
 )
 
-const (
-	applicationAddr = "localhost:6543"
-	applicationURL  = "http://" + applicationAddr
-
-	pingPath      = applicationURL + "/ping/"
-	customersPath = applicationURL + "/customer/"
-	ordersPath    = applicationURL + "/order/"
-	productsPath  = applicationURL + "/product/"
+// apiHandler takes care of communicating with the application api through the
+// typed client, whose format should be the same across all ORMs.
+type apiHandler struct {
+	// baseURL is the URL of the application that the handler talks to.
+	baseURL *url.URL
+	c       *client.Client
+}
 
-	jsonContentType = "application/json"
-)
+func newAPIHandler(baseURL string) apiHandler {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		panic(err)
+	}
+	// The harness has always requested the routes with a trailing slash,
+	// which is the only form that some of the applications route.
+	c, err := client.New(baseURL, client.WithHTTPClient(apiClient), client.WithTrailingSlash())
+	if err != nil {
+		panic(err)
+	}
+	return apiHandler{baseURL: u, c: c}
+}
 
-// apiHandler takes care of communicating with the application api. It uses GORM's models
-// for convenient JSON marshalling/unmarshalling, but this format should be the same
-// across all ORMs.
-type apiHandler struct{}
+// addr returns the address that the application listens on.
+func (h apiHandler) addr() string {
+	return h.baseURL.Host
+}
 
-func (apiHandler) canDial() bool {
-	conn, err := net.Dial("tcp", applicationAddr)
+func (h apiHandler) canDial() bool {
+	conn, err := net.Dial("tcp", h.addr())
 	if err != nil {
 		return false
 	}
@@ -38,114 +49,159 @@ func (apiHandler) canDial() bool {
 	return true
 }
 
-func (apiHandler) ping(expected string) error {
-	resp, err := http.Get(pingPath)
+func (h apiHandler) ping(expected string) error {
+	name, err := h.c.Ping(context.Background())
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("HTTP error %s", resp.Status)
-	}
-	b, err := ioutil.ReadAll(resp.Body)
-	if body := strings.TrimSpace(string(b)); body != expected {
-		return errors.Errorf("ping response %s != expected %s", body, expected)
+	if name != expected {
+		return errors.Errorf("ping response %s != expected %s", name, expected)
 	}
-	return err
+	return nil
 }
 
-func (apiHandler) queryCustomers() ([]model.Customer, error) {
-	var customers []model.Customer
-	if err := getJSON(customersPath, &customers); err != nil {
-		return nil, err
+// ready returns nil once the application reports that it is ready to serve
+// requests. Applications that do not implement /readyz are considered ready
+// once they respond to /ping with their name.
+func (h apiHandler) ready(expected string) error {
+	r, err := h.c.Ready(context.Background())
+	if client.IsNotFound(err) {
+		return h.ping(expected)
+	}
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.Readiness != nil {
+		r = apiErr.Readiness
+	} else if err != nil {
+		return err
 	}
-	return customers, nil
-}
-func (apiHandler) queryProducts() ([]model.Product, error) {
-	var products []model.Product
-	if err := getJSON(productsPath, &products); err != nil {
-		return nil, err
+	if r.App != expected {
+		return errors.Errorf("readyz app %s != expected %s", r.App, expected)
 	}
-	return products, nil
-}
-func (apiHandler) queryOrders() ([]model.Order, error) {
-	var orders []model.Order
-	if err := getJSON(ordersPath, &orders); err != nil {
-		return nil, err
+	if err != nil {
+		return errors.Errorf("app is %s: %v: %v", r.Status, r.Checks, err)
 	}
-	return orders, nil
+	return nil
+}
+
+// openAPISpec returns the OpenAPI document served by the application, or nil
+// if it does not serve one.
+func (h apiHandler) openAPISpec() ([]byte, error) {
+	spec, err := h.c.OpenAPISpec(context.Background())
+	if client.IsNotFound(err) {
+		return nil, nil
+	}
+	return spec, err
+}
+
+func (h apiHandler) queryCustomers() ([]client.Customer, error) {
+	return h.c.ListCustomers(context.Background(), nil)
+}
+func (h apiHandler) queryProducts() ([]client.Product, error) {
+	return h.c.ListProducts(context.Background(), nil)
+}
+func (h apiHandler) queryOrders() ([]client.Order, error) {
+	return h.c.ListOrders(context.Background(), nil)
+}
+
+func (h apiHandler) getCustomer(id int) (*client.Customer, error) {
+	return h.c.GetCustomer(context.Background(), id)
+}
+func (h apiHandler) getProduct(id int) (*client.Product, error) {
+	return h.c.GetProduct(context.Background(), id)
+}
+func (h apiHandler) getOrder(id int) (*client.Order, error) {
+	return h.c.GetOrder(context.Background(), id)
 }
 
-func (apiHandler) createCustomer(name string) error {
-	customer := model.Customer{Name: &name}
-	return postJSONData(customersPath, customer)
+func (h apiHandler) createCustomer(name string) (*client.Customer, error) {
+	return h.c.CreateCustomer(context.Background(), client.Customer{Name: name})
+}
+func (h apiHandler) createProduct(name string, price float64) (*client.Product, error) {
+	return h.c.CreateProduct(context.Background(), client.Product{Name: name, Price: price})
 }
-func (apiHandler) createProduct(name string, price float64) error {
-	product := model.Product{Name: &name, Price: price}
-	return postJSONData(productsPath, product)
+func (h apiHandler) createOrder(customerID, productID int, subtotal float64) (*client.Order, error) {
+	return h.c.CreateOrder(context.Background(), client.Order{
+		Customer: client.Customer{ID: customerID},
+		Products: []client.Product{{ID: productID}},
+		Subtotal: subtotal,
+	})
 }
-func (apiHandler) createOrder(customerID, productID int, subtotal float64) error {
-	order := model.Order{
-		Customer: model.Customer{ID: customerID},
-		Products: []model.Product{{ID: productID}},
+
+func (h apiHandler) updateCustomer(id int, name string) (*client.Customer, error) {
+	return h.c.UpdateCustomer(context.Background(), id, client.Customer{Name: name})
+}
+func (h apiHandler) updateProduct(id int, name string, price float64) (*client.Product, error) {
+	return h.c.UpdateProduct(context.Background(), id, client.Product{Name: name, Price: price})
+}
+
+// updateOrder replaces the subtotal and customer of an order. It replaces the
+// products of the order too, unless productIDs is nil.
+func (h apiHandler) updateOrder(id, customerID int, subtotal float64, productIDs []int) (*client.Order, error) {
+	order := client.Order{
+		Customer: client.Customer{ID: customerID},
 		Subtotal: subtotal,
 	}
-	return postJSONData(ordersPath, order)
+	if productIDs != nil {
+		order.Products = []client.Product{}
+		for _, productID := range productIDs {
+			order.Products = append(order.Products, client.Product{ID: productID})
+		}
+	}
+	return h.c.UpdateOrder(context.Background(), id, order)
+}
+func (h apiHandler) addProductToOrder(orderID, productID int) (*client.Order, error) {
+	return h.c.AddProductToOrder(context.Background(), orderID, productID)
+}
+
+func (h apiHandler) deleteCustomer(ctx context.Context, customerID int) error {
+	return h.c.DeleteCustomer(ctx, customerID)
+}
+func (h apiHandler) deleteProduct(productID int) error {
+	return h.c.DeleteProduct(context.Background(), productID)
+}
+func (h apiHandler) deleteOrder(orderID int) error {
+	return h.c.DeleteOrder(context.Background(), orderID)
 }
 
-func getJSON(path string, result interface{}) error {
-	resp, err := http.Get(path)
+// requestStatus sends a request to path, which may be one that the typed
+// client cannot express, such as one with a malformed ID, and returns the
+// status code of the response.
+func (h apiHandler) requestStatus(method, path string) (int, error) {
+	u, err := h.baseURL.Parse(path)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
+	var body io.Reader
+	if method == http.MethodPost || method == http.MethodPut {
+		body = strings.NewReader("{}")
 	}
-	bbytes, err := ioutil.ReadAll(resp.Body)
+	req, err := http.NewRequest(method, u.String(), body)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	body := string(bbytes)
-	return errors.Wrapf(json.Unmarshal(bbytes, result), "getJSON(%s) : %s", path, body)
-}
-
-func postJSONData(path string, body interface{}) error {
-	var bodyBuf bytes.Buffer
-	if err := json.NewEncoder(&bodyBuf).Encode(body); err != nil {
-		return err
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-
-	resp, err := http.Post(path, jsonContentType, &bodyBuf)
+	resp, err := apiClient.Do(req)
 	if err != nil {
-		return err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
+		return 0, err
 	}
-	return nil
+	defer resp.Body.Close()
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, err
 }
 
 // These functions clean any non-deterministic fields, such as IDs that are
 // generated upon row creation.
-func cleanCustomers(customers []model.Customer) []model.Customer {
+func cleanCustomers(customers []client.Customer) []client.Customer {
 	for i := range customers {
 		customers[i].ID = 0
 	}
 	return customers
 }
-func cleanProducts(products []model.Product) []model.Product {
+func cleanProducts(products []client.Product) []client.Product {
 	for i := range products {
 		products[i].ID = 0
 	}
 	return products
 }
-func cleanOrders(orders []model.Order) []model.Order {
-	for i := range orders {
-		orders[i].ID = 0
-		orders[i].Customer = model.Customer{}
-		orders[i].CustomerID = 0
-		orders[i].Products = nil
-	}
-	return orders
-}
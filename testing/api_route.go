@@ -0,0 +1,88 @@
+package testing
+
+import "testing"
+
+// apiRoute is a route of the REST API that addresses a single object. Every
+// application serves the collections, but not every one serves these routes.
+type apiRoute string
+
+const (
+	getCustomerRoute       apiRoute = "GET /customer/{id}"
+	updateCustomerRoute    apiRoute = "PUT /customer/{id}"
+	deleteCustomerRoute    apiRoute = "DELETE /customer/{id}"
+	getProductRoute        apiRoute = "GET /product/{id}"
+	updateProductRoute     apiRoute = "PUT /product/{id}"
+	deleteProductRoute     apiRoute = "DELETE /product/{id}"
+	getOrderRoute          apiRoute = "GET /order/{id}"
+	updateOrderRoute       apiRoute = "PUT /order/{id}"
+	deleteOrderRoute       apiRoute = "DELETE /order/{id}"
+	addProductToOrderRoute apiRoute = "POST /order/{id}/product"
+)
+
+// routeSkips maps routes to the reason that the tests of an application skip
+// them.
+type routeSkips map[apiRoute]string
+
+// skipRoutes returns routeSkips that map each of routes to reason.
+func skipRoutes(reason string, routes ...apiRoute) routeSkips {
+	skips := make(routeSkips, len(routes))
+	for _, r := range routes {
+		skips[r] = reason
+	}
+	return skips
+}
+
+// and returns the skips of both s and other.
+func (s routeSkips) and(other routeSkips) routeSkips {
+	skips := make(routeSkips, len(s)+len(other))
+	for r, reason := range s {
+		skips[r] = reason
+	}
+	for r, reason := range other {
+		skips[r] = reason
+	}
+	return skips
+}
+
+// routeSupport describes how an application serves the routes that address
+// single objects. The zero value describes an application that serves every
+// route as the REST API's contract requires.
+type routeSupport struct {
+	// unserved maps the routes that the application does not serve, or serves
+	// too differently from the contract to be tested, to the reason.
+	unserved routeSkips
+	// missingIDs maps the routes that do not respond to IDs that do not exist
+	// with 404 Not Found to the reason.
+	missingIDs routeSkips
+	// malformedIDs maps the routes that do not respond to IDs that are not
+	// integers with 400 Bad Request to the reason.
+	malformedIDs routeSkips
+}
+
+// skipUnlessServed skips t unless the application serves all of routes.
+func (rs routeSupport) skipUnlessServed(t *testing.T, routes ...apiRoute) {
+	t.Helper()
+	for _, r := range routes {
+		if reason, ok := rs.unserved[r]; ok {
+			t.Skipf("application does not serve %s: %s", r, reason)
+		}
+	}
+}
+
+// missingIDReason returns the reason that route is not expected to respond to
+// missing IDs with 404 Not Found, or "" if it is.
+func (rs routeSupport) missingIDReason(route apiRoute) string {
+	if reason, ok := rs.unserved[route]; ok {
+		return "application does not serve it: " + reason
+	}
+	return rs.missingIDs[route]
+}
+
+// malformedIDReason returns the reason that route is not expected to respond
+// to malformed IDs with 400 Bad Request, or "" if it is.
+func (rs routeSupport) malformedIDReason(route apiRoute) string {
+	if reason, ok := rs.unserved[route]; ok {
+		return "application does not serve it: " + reason
+	}
+	return rs.malformedIDs[route]
+}
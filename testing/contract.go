@@ -0,0 +1,159 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// apiClient is the client that the tests talk to the applications with. It
+// checks every response against the REST API's contract.
+var apiClient = &http.Client{Transport: apiContract}
+
+// apiContract validates the responses received by apiClient.
+var apiContract = newContractValidator(http.DefaultTransport)
+
+// contractValidator is an http.RoundTripper that validates every response of
+// an application against the OpenAPI document of the sample application's
+// REST API, and records the responses that violate it. Responses to requests
+// outside of the document, such as /ping, are not validated.
+type contractValidator struct {
+	next   http.RoundTripper
+	router routers.Router
+	// withoutOrderRelations routes a copy of the document in which the
+	// customer and products of orders are optional.
+	withoutOrderRelations routers.Router
+
+	mu         sync.Mutex
+	violations map[string][]string // by application address
+	// relationless holds the addresses of the applications whose responses
+	// are validated with withoutOrderRelations.
+	relationless map[string]bool
+}
+
+func newContractValidator(next http.RoundTripper) *contractValidator {
+	return &contractValidator{
+		next:                  next,
+		router:                routeDocument(func(*openapi3.T) {}),
+		withoutOrderRelations: routeDocument(dropOrderRelations),
+		violations:            make(map[string][]string),
+		relationless:          make(map[string]bool),
+	}
+}
+
+// routeDocument loads the OpenAPI document, applies edit to it and routes it.
+func routeDocument(edit func(doc *openapi3.T)) routers.Router {
+	doc, err := openapi3.NewLoader().LoadFromData(api.OpenAPISpec())
+	if err != nil {
+		panic(fmt.Sprintf("failed to load OpenAPI document: %v", err))
+	}
+	edit(doc)
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		panic(fmt.Sprintf("failed to route OpenAPI document: %v", err))
+	}
+	return router
+}
+
+// dropOrderRelations makes the customer and products of orders optional, and
+// accepts them in any shape.
+func dropOrderRelations(doc *openapi3.T) {
+	order := doc.Components.Schemas["Order"].Value
+	order.Required = []string{"id", "subtotal"}
+	for _, relation := range []string{"customer", "products"} {
+		order.Properties[relation] = openapi3.NewSchemaRef("", &openapi3.Schema{Nullable: true})
+	}
+}
+
+// allowMissingOrderRelations validates the responses of the application at
+// addr without requiring orders to come with their customer and products,
+// until the returned function is called. The tests of orders report the
+// relations that such applications are missing instead.
+func (v *contractValidator) allowMissingOrderRelations(addr string) func() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.relationless[addr] = true
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		delete(v.relationless, addr)
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (v *contractValidator) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := v.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	router := v.router
+	v.mu.Lock()
+	if v.relationless[req.URL.Host] {
+		router = v.withoutOrderRelations
+	}
+	v.mu.Unlock()
+	routeReq := withoutTrailingSlash(req)
+	route, pathParams, err := router.FindRoute(routeReq)
+	if err != nil {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    routeReq,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   ioutil.NopCloser(bytes.NewReader(body)),
+	})
+	if err != nil {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.violations[req.URL.Host] = append(v.violations[req.URL.Host],
+			fmt.Sprintf("%s %s: %d response %q: %v", req.Method, req.URL.RequestURI(), resp.StatusCode, body, err))
+	}
+	return resp, nil
+}
+
+// withoutTrailingSlash returns req with the trailing slash of its path, if
+// any, removed. The tests request collections with a trailing slash, which the
+// document does not describe but the applications accept.
+func withoutTrailingSlash(req *http.Request) *http.Request {
+	if len(req.URL.Path) <= 1 || !strings.HasSuffix(req.URL.Path, "/") {
+		return req
+	}
+	u := *req.URL
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	r := req.Clone(req.Context())
+	r.URL = &u
+	return r
+}
+
+// takeViolations returns the contract violations of the responses of the
+// application at addr since the last call.
+func (v *contractValidator) takeViolations(addr string) []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	violations := v.violations[addr]
+	delete(v.violations, addr)
+	return violations
+}
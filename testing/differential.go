@@ -0,0 +1,594 @@
+package testing
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The differential test generates a pseudo-random sequence of API operations,
+// replays it against every application, and compares their responses and the
+// resulting contents of their tables step by step. Since every application
+// generates its own IDs, operations refer to objects by the order in which
+// they were created, and IDs in responses and tables are replaced by such
+// references before comparing them.
+
+// entityKind is the kind of object that an operation or ID refers to.
+type entityKind int
+
+const (
+	customerKind entityKind = iota
+	productKind
+	orderKind
+)
+
+func (k entityKind) String() string {
+	return [...]string{"customer", "product", "order"}[k]
+}
+
+// References to objects are the 1-based positions of the operations that
+// create them among the creations of objects of their kind, whether or not
+// the creations succeed. The following references are invalid on purpose.
+const (
+	// noRef leaves the ID out of a request.
+	noRef = 0
+	// missingRef refers to an ID that no object has.
+	missingRef = -1
+	// malformedRef refers to an ID that is not a number, and is only used in
+	// paths.
+	malformedRef = -2
+)
+
+// missingID is the ID that missingRef and the references to objects whose
+// creation failed resolve to. IDs generated by unique_rowid() are smaller,
+// and it is exactly representable as a JavaScript number.
+const missingID = 1 << 62
+
+// diffOp is an operation of the differential test.
+type diffOp struct {
+	method string
+	kind   entityKind
+	// id is the object that the path refers to, or noRef for the collection.
+	id int
+	// creates is the reference to the object that the operation creates.
+	creates int
+
+	// The fields of the request body. Orders refer to their customer, and to
+	// their products unless products is nil.
+	name string
+	// price is the price of products and the subtotal of orders.
+	price    float64
+	customer int
+	products []int
+	// product is the productID query parameter of adding a product to an
+	// order.
+	product int
+}
+
+// isAddProduct reports whether the operation adds a product to an order.
+func (op diffOp) isAddProduct() bool {
+	return op.kind == orderKind && op.method == http.MethodPost && op.id != noRef
+}
+
+// request returns the path and body of the operation's request, with
+// references resolved to IDs by resolve. Paths have a trailing slash, like
+// the paths that the rest of the harness requests, since some applications
+// only route those.
+func (op diffOp) request(resolve func(kind entityKind, ref int) string) (string, string) {
+	path := "/" + op.kind.String() + "/"
+	if op.id != noRef {
+		path += resolve(op.kind, op.id) + "/"
+	}
+	if op.isAddProduct() {
+		path += "product/"
+		if op.product != noRef {
+			path += "?productID=" + resolve(productKind, op.product)
+		}
+		return path, ""
+	}
+	if op.method != http.MethodPost && op.method != http.MethodPut {
+		return path, ""
+	}
+
+	var body string
+	switch op.kind {
+	case customerKind:
+		body = fmt.Sprintf(`{"name":%q}`, op.name)
+	case productKind:
+		body = fmt.Sprintf(`{"name":%q,"price":"%.2f"}`, op.name, op.price)
+	case orderKind:
+		body = fmt.Sprintf(`{"subtotal":"%.2f","customer":{`, op.price)
+		if op.customer != noRef {
+			body += `"id":` + resolve(customerKind, op.customer)
+		}
+		body += "}"
+		if op.products != nil {
+			ids := make([]string, len(op.products))
+			for i, ref := range op.products {
+				ids[i] = `{"id":` + resolve(productKind, ref) + `}`
+			}
+			body += `,"products":[` + strings.Join(ids, ",") + `]`
+		}
+		body += "}"
+	}
+	return path, body
+}
+
+// String describes the operation with its references, as it is reported.
+func (op diffOp) String() string {
+	path, body := op.request(func(kind entityKind, ref int) string {
+		return describeRef(kind, ref)
+	})
+	s := op.method + " " + path
+	if body != "" {
+		s += " " + body
+	}
+	if op.creates != noRef {
+		s += " (creates " + describeRef(op.kind, op.creates) + ")"
+	}
+	return s
+}
+
+func describeRef(kind entityKind, ref int) string {
+	switch ref {
+	case missingRef:
+		return kind.String() + "#missing"
+	case malformedRef:
+		return "malformed"
+	default:
+		return fmt.Sprintf("%s#%d", kind, ref)
+	}
+}
+
+// Names are drawn from small sets, so that products with duplicate names are
+// created and renamed to. The empty names are invalid.
+var (
+	diffCustomerNames = []string{"Carl", "Bobby", "Dana", ""}
+	diffProductNames  = []string{"Ice Cream", "Fudge", "Sprinkles", ""}
+)
+
+// generateOps generates a sequence of operations that is determined by seed.
+// Operations mostly refer to objects that earlier operations created, which
+// may have failed or been deleted since, and sometimes to missing or
+// malformed IDs.
+func generateOps(seed int64, steps int) []diffOp {
+	rng := rand.New(rand.NewSource(seed))
+	var created [3]int
+	ref := func(kind entityKind, allowMalformed bool) int {
+		switch n := rng.Intn(20); {
+		case n == 0:
+			return missingRef
+		case n == 1 && allowMalformed:
+			return malformedRef
+		case created[kind] == 0:
+			return missingRef
+		default:
+			return 1 + rng.Intn(created[kind])
+		}
+	}
+	price := func() float64 {
+		return float64(1+rng.Intn(10000)) / 100
+	}
+	fillBody := func(op *diffOp) {
+		switch op.kind {
+		case customerKind:
+			op.name = diffCustomerNames[rng.Intn(len(diffCustomerNames))]
+		case productKind:
+			op.name = diffProductNames[rng.Intn(len(diffProductNames))]
+			op.price = price()
+		case orderKind:
+			op.price = price()
+			if rng.Intn(10) > 0 {
+				op.customer = ref(customerKind, false)
+			}
+			if op.method == http.MethodPost || rng.Intn(2) == 0 {
+				op.products = []int{}
+				for i := rng.Intn(3); i > 0; i-- {
+					op.products = append(op.products, ref(productKind, false))
+				}
+			}
+		}
+	}
+
+	ops := make([]diffOp, steps)
+	for i := range ops {
+		op := diffOp{kind: entityKind(rng.Intn(3))}
+		switch n := rng.Intn(10); {
+		case n < 4 || created[op.kind] == 0:
+			op.method = http.MethodPost
+			created[op.kind]++
+			op.creates = created[op.kind]
+			fillBody(&op)
+		case n < 6:
+			op.method = http.MethodPut
+			op.id = ref(op.kind, true)
+			fillBody(&op)
+		case n < 7:
+			op.method = http.MethodDelete
+			op.id = ref(op.kind, true)
+		case n < 8:
+			op.method = http.MethodGet
+			op.id = ref(op.kind, true)
+		default:
+			// Add a product to an order, or to a missing one if there are
+			// no orders, leaving the product out sometimes.
+			op.kind = orderKind
+			op.method = http.MethodPost
+			op.id = ref(orderKind, true)
+			if rng.Intn(10) > 0 {
+				op.product = ref(productKind, true)
+			}
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+// diffResult is the outcome of an operation on an application, with the IDs
+// that the application generated replaced by references. Bodies are only
+// compared for successful responses, since the contract of the REST API
+// only specifies the status codes of errors.
+type diffResult struct {
+	status int
+	body   string
+	tables string
+}
+
+// diffReplayer replays operations against an application, keeping track of
+// the IDs that the application generated for the objects that they created.
+type diffReplayer struct {
+	td   testDriver
+	ids  [3]map[int]int // references to IDs, by kind
+	refs [3]map[int]int // IDs to references, by kind
+}
+
+func newDiffReplayer(td testDriver) *diffReplayer {
+	r := &diffReplayer{td: td}
+	for kind := range r.ids {
+		r.ids[kind] = make(map[int]int)
+		r.refs[kind] = make(map[int]int)
+	}
+	return r
+}
+
+// resolve returns the ID that a reference refers to in the application.
+func (r *diffReplayer) resolve(kind entityKind, ref int) string {
+	if ref == malformedRef {
+		return "abc"
+	}
+	if id, ok := r.ids[kind][ref]; ok {
+		return strconv.Itoa(id)
+	}
+	return strconv.Itoa(missingID)
+}
+
+// describeID returns the reference to an object that the application
+// generated an ID for.
+func (r *diffReplayer) describeID(kind entityKind, id int) string {
+	if ref, ok := r.refs[kind][id]; ok {
+		return describeRef(kind, ref)
+	}
+	if id == missingID {
+		return describeRef(kind, missingRef)
+	}
+	return kind.String() + "#unknown"
+}
+
+// apply sends the request of an operation to the application, and returns
+// its outcome. Failures to send the request are outcomes too, since they
+// are how applications that crash differ from the others.
+func (r *diffReplayer) apply(t *testing.T, op diffOp) diffResult {
+	path, body := op.request(r.resolve)
+	status, respBody, err := r.send(op.method, path, body)
+	if err != nil {
+		return diffResult{body: fmt.Sprintf("error: %v", err), tables: r.dumpTables(t)}
+	}
+
+	if op.creates != noRef && status/100 == 2 {
+		// The application might not return the created object, so the ID
+		// that it generated is found in its table.
+		var newIDs []int
+		for _, id := range r.tableIDs(t, op.kind) {
+			if _, ok := r.refs[op.kind][id]; !ok {
+				newIDs = append(newIDs, id)
+			}
+		}
+		if len(newIDs) == 1 {
+			r.ids[op.kind][op.creates] = newIDs[0]
+			r.refs[op.kind][newIDs[0]] = op.creates
+		}
+	}
+
+	res := diffResult{status: status, tables: r.dumpTables(t)}
+	if status/100 == 2 {
+		res.body = normalizeBody(respBody, op.kind, r.describeID)
+	}
+	return res
+}
+
+func (r *diffReplayer) send(method, path, body string) (int, []byte, error) {
+	u, err := r.td.api.baseURL.Parse(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), strings.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, b, err
+}
+
+func (r *diffReplayer) tableIDs(t *testing.T, kind entityKind) []int {
+	table := [...]string{
+		r.td.tableNames.customersTable,
+		r.td.tableNames.productsTable,
+		r.td.tableNames.ordersTable,
+	}[kind]
+	rows, err := r.td.db.Query(fmt.Sprintf(`SELECT id FROM %s`, table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// dumpTables returns the sorted rows of the application's tables, with IDs
+// replaced by references. The columns other than IDs can be NULL, since the
+// operations leave fields out, such as the customer of an order, and the
+// applications do not all reject them; they are printed as NULL.
+func (r *diffReplayer) dumpTables(t *testing.T) string {
+	var lines []string
+	lines = append(lines, r.queryLines(t, fmt.Sprintf(`SELECT id, name FROM %s`,
+		r.td.tableNames.customersTable), func(rows *sql.Rows) (string, error) {
+		var id int
+		var name sql.NullString
+		err := rows.Scan(&id, &name)
+		return fmt.Sprintf("customers: %s %s", r.describeID(customerKind, id), formatNullString(name)), err
+	})...)
+	lines = append(lines, r.queryLines(t, fmt.Sprintf(`SELECT id, name, price::FLOAT8 FROM %s`,
+		r.td.tableNames.productsTable), func(rows *sql.Rows) (string, error) {
+		var id int
+		var name sql.NullString
+		var price sql.NullFloat64
+		err := rows.Scan(&id, &name, &price)
+		return fmt.Sprintf("products: %s %s %s", r.describeID(productKind, id),
+			formatNullString(name), formatNullFloat(price)), err
+	})...)
+	lines = append(lines, r.queryLines(t, fmt.Sprintf(`SELECT id, customer_id, subtotal::FLOAT8 FROM %s`,
+		r.td.tableNames.ordersTable), func(rows *sql.Rows) (string, error) {
+		var id int
+		var customerID sql.NullInt64
+		var subtotal sql.NullFloat64
+		err := rows.Scan(&id, &customerID, &subtotal)
+		customer := "NULL"
+		if customerID.Valid {
+			customer = r.describeID(customerKind, int(customerID.Int64))
+		}
+		return fmt.Sprintf("orders: %s %s %s", r.describeID(orderKind, id),
+			customer, formatNullFloat(subtotal)), err
+	})...)
+
+	// The columns referring to orders and products are the last two columns
+	// of the table, whatever they are called.
+	cols := r.td.columnNames.ordersProductsColumns
+	lines = append(lines, r.queryLines(t, fmt.Sprintf(`SELECT %s, %s FROM %s`,
+		cols[len(cols)-2], cols[len(cols)-1], r.td.tableNames.orderProductsTable), func(rows *sql.Rows) (string, error) {
+		var orderID, productID int
+		err := rows.Scan(&orderID, &productID)
+		return fmt.Sprintf("order_products: %s %s", r.describeID(orderKind, orderID),
+			r.describeID(productKind, productID)), err
+	})...)
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func formatNullString(s sql.NullString) string {
+	if !s.Valid {
+		return "NULL"
+	}
+	return strconv.Quote(s.String)
+}
+
+func formatNullFloat(f sql.NullFloat64) string {
+	if !f.Valid {
+		return "NULL"
+	}
+	return strconv.FormatFloat(f.Float64, 'f', -1, 64)
+}
+
+// queryLines returns a line for each row of a query.
+func (r *diffReplayer) queryLines(
+	t *testing.T, query string, line func(*sql.Rows) (string, error),
+) []string {
+	rows, err := r.td.db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		l, err := line(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+// normalizeBody replaces the IDs in a JSON response body about objects of
+// the given kind by references, formats prices and subtotals as numbers
+// without trailing zeros, and encodes the body again with sorted keys. Bodies
+// that are not JSON are only trimmed.
+func normalizeBody(body []byte, kind entityKind, describeID func(entityKind, int) string) string {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return string(bytes.TrimSpace(body))
+	}
+
+	var walk func(v interface{}, kind entityKind) interface{}
+	walk = func(v interface{}, kind entityKind) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, field := range v {
+				switch key {
+				case "id":
+					v[key] = normalizeID(field, kind, describeID)
+				case "customer_id":
+					v[key] = normalizeID(field, customerKind, describeID)
+				case "customer":
+					v[key] = walk(field, customerKind)
+				case "products":
+					v[key] = walk(field, productKind)
+				case "price", "subtotal":
+					if f, err := strconv.ParseFloat(fmt.Sprint(field), 64); err == nil {
+						v[key] = strconv.FormatFloat(f, 'f', -1, 64)
+					}
+				default:
+					v[key] = walk(field, kind)
+				}
+			}
+		case []interface{}:
+			for i := range v {
+				v[i] = walk(v[i], kind)
+			}
+		}
+		return v
+	}
+	b, err := json.Marshal(walk(v, kind))
+	if err != nil {
+		return string(bytes.TrimSpace(body))
+	}
+	return string(b)
+}
+
+func normalizeID(v interface{}, kind entityKind, describeID func(entityKind, int) string) interface{} {
+	id, err := strconv.Atoi(fmt.Sprint(v))
+	if err != nil {
+		return v
+	}
+	return describeID(kind, id)
+}
+
+// divergence is the first step at which the outcomes of some applications
+// differ from the outcome of the majority of them.
+type divergence struct {
+	step   int
+	aspect string // "status", "body" or "tables"
+	// majority is the outcome of the majority, and majorityApps the
+	// applications that it is the outcome of.
+	majority     string
+	majorityApps []string
+	// diverging holds the outcomes of the other applications, by name.
+	diverging map[string]string
+}
+
+// firstDivergence compares the outcomes of each step across applications,
+// and returns the first step at which an application's response status,
+// response body or tables differ from those of the majority, or nil if the
+// applications agree on every step. results holds the outcomes of the
+// applications in the order of apps, which must all have replayed every
+// step. Ties between majorities are broken in favor of the first application.
+func firstDivergence(apps []string, steps int, results [][]diffResult) *divergence {
+	aspects := []struct {
+		name  string
+		value func(diffResult) string
+	}{
+		{"status", func(res diffResult) string { return strconv.Itoa(res.status) }},
+		{"body", func(res diffResult) string { return res.body }},
+		{"tables", func(res diffResult) string { return res.tables }},
+	}
+	for step := 0; step < steps; step++ {
+		for _, aspect := range aspects {
+			values := make([]string, len(apps))
+			counts := make(map[string]int)
+			for i := range apps {
+				values[i] = aspect.value(results[i][step])
+				counts[values[i]]++
+			}
+			majority := values[0]
+			for _, v := range values {
+				if counts[v] > counts[majority] {
+					majority = v
+				}
+			}
+			if counts[majority] == len(apps) {
+				continue
+			}
+			d := &divergence{
+				step:      step,
+				aspect:    aspect.name,
+				majority:  majority,
+				diverging: make(map[string]string),
+			}
+			for i, app := range apps {
+				if values[i] == majority {
+					d.majorityApps = append(d.majorityApps, app)
+				} else {
+					d.diverging[app] = values[i]
+				}
+			}
+			return d
+		}
+	}
+	return nil
+}
+
+// report describes the divergence and the operations that led to it.
+func (d *divergence) report(ops []diffOp) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "operations up to the divergence:\n")
+	for i := 0; i <= d.step; i++ {
+		fmt.Fprintf(&buf, "  %d: %s\n", i+1, ops[i])
+	}
+	indent := func(s string) string {
+		if s == "" {
+			s = "(empty)"
+		}
+		return strings.ReplaceAll(s, "\n", "\n    ")
+	}
+	fmt.Fprintf(&buf, "at step %d, the %s of the majority (%s) is:\n    %s\n",
+		d.step+1, d.aspect, strings.Join(d.majorityApps, ", "), indent(d.majority))
+	var apps []string
+	for app := range d.diverging {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	for _, app := range apps {
+		fmt.Fprintf(&buf, "but the %s of %s is:\n    %s\n", d.aspect, app, indent(d.diverging[app]))
+	}
+	return buf.String()
+}
@@ -0,0 +1,181 @@
+package testing
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateOps(t *testing.T) {
+	ops := generateOps(1, 500)
+	if !reflect.DeepEqual(ops, generateOps(1, 500)) {
+		t.Fatal("expected the same seed to generate the same operations")
+	}
+	if reflect.DeepEqual(ops, generateOps(2, 500)) {
+		t.Fatal("expected different seeds to generate different operations")
+	}
+
+	var created [3]int
+	var missing, malformed, addProducts int
+	for i, op := range ops {
+		if op.creates != noRef {
+			created[op.kind]++
+			if op.creates != created[op.kind] {
+				t.Fatalf("operation %d: expected to create %s, found %s",
+					i, describeRef(op.kind, created[op.kind]), op)
+			}
+		}
+		if op.id > created[op.kind] {
+			t.Fatalf("operation %d refers to an object that was not created yet: %s", i, op)
+		}
+		switch op.id {
+		case missingRef:
+			missing++
+		case malformedRef:
+			malformed++
+		}
+		if op.isAddProduct() {
+			addProducts++
+		}
+	}
+	if missing == 0 || malformed == 0 || addProducts == 0 {
+		t.Errorf("expected operations on missing and malformed IDs and additions of products, "+
+			"found %d, %d and %d", missing, malformed, addProducts)
+	}
+}
+
+func TestDiffOpRequest(t *testing.T) {
+	resolve := func(kind entityKind, ref int) string {
+		if ref == malformedRef {
+			return "abc"
+		}
+		return strconv.Itoa(100*int(kind) + ref)
+	}
+	testCases := []struct {
+		op         diffOp
+		path, body string
+	}{
+		{
+			op:   diffOp{method: http.MethodPost, kind: customerKind, creates: 1, name: "Carl"},
+			path: "/customer/",
+			body: `{"name":"Carl"}`,
+		},
+		{
+			op:   diffOp{method: http.MethodPut, kind: productKind, id: 2, name: "Fudge", price: 4.5},
+			path: "/product/102/",
+			body: `{"name":"Fudge","price":"4.50"}`,
+		},
+		{
+			op:   diffOp{method: http.MethodPost, kind: orderKind, creates: 1, price: 1, customer: 1, products: []int{1, 2}},
+			path: "/order/",
+			body: `{"subtotal":"1.00","customer":{"id":1},"products":[{"id":101},{"id":102}]}`,
+		},
+		{
+			// Orders without a customer, keeping their products.
+			op:   diffOp{method: http.MethodPut, kind: orderKind, id: 1, price: 1},
+			path: "/order/201/",
+			body: `{"subtotal":"1.00","customer":{}}`,
+		},
+		{
+			op:   diffOp{method: http.MethodPost, kind: orderKind, id: 1, product: 2},
+			path: "/order/201/product/?productID=102",
+		},
+		{
+			op:   diffOp{method: http.MethodDelete, kind: customerKind, id: malformedRef},
+			path: "/customer/abc/",
+		},
+	}
+	for _, tc := range testCases {
+		path, body := tc.op.request(resolve)
+		if path != tc.path || body != tc.body {
+			t.Errorf("%s: expected %s %s, found %s %s", tc.op, tc.path, tc.body, path, body)
+		}
+	}
+}
+
+func TestNormalizeBody(t *testing.T) {
+	describeID := func(kind entityKind, id int) string {
+		return describeRef(kind, id/10)
+	}
+	testCases := []struct {
+		kind     entityKind
+		body     string
+		expected string
+	}{
+		{
+			kind:     productKind,
+			body:     `{"id": 10, "name": "Fudge", "price": "4.50"}`,
+			expected: `{"id":"product#1","name":"Fudge","price":"4.5"}`,
+		},
+		{
+			kind: orderKind,
+			body: `{"subtotal": 1.0, "id": 20, "customer": {"id": 30, "name": "Carl"},
+				"products": [{"id": 10, "name": "Fudge", "price": "4.50"}]}`,
+			expected: `{"customer":{"id":"customer#3","name":"Carl"},"id":"order#2",` +
+				`"products":[{"id":"product#1","name":"Fudge","price":"4.5"}],"subtotal":"1"}`,
+		},
+		{
+			kind:     orderKind,
+			body:     `{"id": "20", "customer_id": 30}`,
+			expected: `{"customer_id":"customer#3","id":"order#2"}`,
+		},
+		{
+			kind:     customerKind,
+			body:     "ok\n",
+			expected: "ok",
+		},
+	}
+	for _, tc := range testCases {
+		if found := normalizeBody([]byte(tc.body), tc.kind, describeID); found != tc.expected {
+			t.Errorf("expected %s, found %s", tc.expected, found)
+		}
+	}
+}
+
+func TestFirstDivergence(t *testing.T) {
+	apps := []string{"go/gopg", "go/gorm", "python/django"}
+	agreed := diffResult{status: 200, body: `{"id":"customer#1","name":"Carl"}`, tables: `customers: customer#1 "Carl"`}
+	results := [][]diffResult{
+		{agreed, {status: 404}, {status: 200, tables: "a"}},
+		{agreed, {status: 404}, {status: 200, tables: "b"}},
+		{agreed, {status: 404}, {status: 200, tables: "a"}},
+	}
+	d := firstDivergence(apps, 3, results)
+	if d == nil {
+		t.Fatal("expected a divergence")
+	}
+	expected := &divergence{
+		step:         2,
+		aspect:       "tables",
+		majority:     "a",
+		majorityApps: []string{"go/gopg", "python/django"},
+		diverging:    map[string]string{"go/gorm": "b"},
+	}
+	if !reflect.DeepEqual(expected, d) {
+		t.Errorf("expected %+v, found %+v", expected, d)
+	}
+
+	ops := []diffOp{
+		{method: http.MethodPost, kind: customerKind, creates: 1, name: "Carl"},
+		{method: http.MethodGet, kind: customerKind, id: missingRef},
+		{method: http.MethodDelete, kind: customerKind, id: 1},
+	}
+	report := d.report(ops)
+	for _, line := range []string{
+		`  1: POST /customer/ {"name":"Carl"} (creates customer#1)`,
+		"  2: GET /customer/customer#missing/",
+		"  3: DELETE /customer/customer#1/",
+		"at step 3, the tables of the majority (go/gopg, python/django) is:\n    a",
+		"but the tables of go/gorm is:\n    b",
+	} {
+		if !strings.Contains(report, line+"\n") {
+			t.Errorf("expected report to contain %q, found:\n%s", line, report)
+		}
+	}
+
+	if d := firstDivergence(apps[:1], 3, results[:1]); d != nil {
+		t.Errorf("expected no divergence, found %+v", d)
+	}
+}
@@ -0,0 +1,108 @@
+package testing
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/gopg/gopgstore"
+	"github.com/cockroachdb/examples-orms/go/gorm/gormstore"
+)
+
+// The ways in which applications can be launched.
+const (
+	// subprocessLauncher launches applications with `make start`.
+	subprocessLauncher = "subprocess"
+	// inProcessLauncher serves the Go applications from the test binary, so
+	// that their code is covered by -cover and checked by -race. Other
+	// applications are still launched as subprocesses.
+	inProcessLauncher = "inprocess"
+)
+
+// inProcessRequestTimeout is the -request-timeout of the Go applications,
+// which applies to them when they are served in-process.
+const inProcessRequestTimeout = 30 * time.Second
+
+// inProcessStore is the Store of a Go application that can be served
+// in-process.
+type inProcessStore interface {
+	api.Store
+	ReadinessChecks() []api.ReadinessCheck
+	Close() error
+}
+
+// inProcessApps opens the stores of the applications that can be served
+// in-process.
+var inProcessApps = map[application]func(addr string, logger *api.Logger, metrics *api.Metrics) (inProcessStore, error){
+	{language: "go", orm: "gorm"}: func(addr string, logger *api.Logger, metrics *api.Metrics) (inProcessStore, error) {
+		db, err := gormstore.Open(addr, logger)
+		if err != nil {
+			return nil, err
+		}
+		return gormstore.New(db, metrics), nil
+	},
+	{language: "go", orm: "gopg"}: func(addr string, logger *api.Logger, metrics *api.Metrics) (inProcessStore, error) {
+		db, err := gopgstore.Open(addr, logger)
+		if err != nil {
+			return nil, err
+		}
+		return gopgstore.New(db, metrics), nil
+	},
+}
+
+// launchApp launches an ORM application with the given launcher, listening on
+// the port of h's base URL, and returns a function that stops it.
+func launchApp(launcher string, app application, dbURL *url.URL, h apiHandler) (func() error, error) {
+	switch launcher {
+	case subprocessLauncher:
+		return initORMApp(app, dbURL, h)
+	case inProcessLauncher:
+		if open, ok := inProcessApps[app]; ok {
+			return startInProcessApp(app, open, dbURL, h)
+		}
+		return initORMApp(app, dbURL, h)
+	default:
+		return nil, fmt.Errorf("unknown launcher %q", launcher)
+	}
+}
+
+// startInProcessApp serves a Go application from the test binary, on the
+// address of h's base URL.
+func startInProcessApp(
+	app application,
+	open func(addr string, logger *api.Logger, metrics *api.Metrics) (inProcessStore, error),
+	dbURL *url.URL,
+	h apiHandler,
+) (func() error, error) {
+	logger := api.NewLogger(os.Stderr)
+	metrics := api.NewMetrics()
+	store, err := open(dbURL.String(), logger, metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the store of %s: %v", app.name(), err)
+	}
+	server := api.NewServer(app.name(), store, metrics, store.ReadinessChecks()...)
+
+	l, err := net.Listen("tcp", h.addr())
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	srv := httptest.NewUnstartedServer(server.Handler(logger, inProcessRequestTimeout))
+	_ = srv.Listener.Close()
+	srv.Listener = l
+	srv.Start()
+
+	if err := h.ready(app.name()); err != nil {
+		srv.Close()
+		_ = store.Close()
+		return nil, err
+	}
+	return func() error {
+		srv.Close()
+		return store.Close()
+	}, nil
+}
@@ -2,11 +2,15 @@ package testing
 
 import (
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -17,6 +21,36 @@ import (
 	_ "github.com/lib/pq"
 )
 
+var launcher = flag.String("launcher", subprocessLauncher,
+	"how to launch the applications: subprocess, which runs `make start`, or inprocess, "+
+		"which serves the Go applications from the test binary and launches the others as subprocesses")
+
+var schemaReportPath = flag.String("schema-report", "",
+	"if set, the file to write a side-by-side comparison of the schemas that the applications generate to, "+
+		"as HTML if it ends with .html and as Markdown otherwise")
+
+var differentialSteps = flag.Int("differential-steps", 0,
+	"if positive, the number of pseudo-random API operations that TestDifferential replays against every application, "+
+		"reporting the first one after which an application's response or tables differ from the majority's")
+
+var differentialSeed = flag.Int64("differential-seed", 1,
+	"the seed of the operations that TestDifferential generates")
+
+var differentialApps = flag.String("differential-apps", "",
+	"if set, the comma-separated names of the applications that TestDifferential compares, such as go/gorm,go/gopg")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	if *schemaReportPath != "" {
+		if err := generatedSchemas.write(*schemaReportPath); err != nil {
+			log.Printf("failed to write the schema report: %v", err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
 // application represents a single instance of an application running an ORM and
 // exposing an HTTP REST API.
 type application struct {
@@ -124,10 +158,21 @@ func getVersionFromDB(t *testing.T, db *sql.DB) *version.Version {
 	return v
 }
 
-// initORMApp launches an ORM application as a subprocess and returns a
-// function that terminates that process.
-func initORMApp(app application, dbURL *url.URL) (func() error, error) {
-	cmd := exec.Command("make", "start", "-C", app.dir(), "ADDR="+dbURL.String())
+// freeAddr returns a local address with a port that is free to listen on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return fmt.Sprintf("localhost:%d", l.Addr().(*net.TCPAddr).Port)
+}
+
+// initORMApp launches an ORM application as a subprocess, listening on the
+// port of h's base URL, and returns a function that terminates that process.
+func initORMApp(app application, dbURL *url.URL, h apiHandler) (func() error, error) {
+	cmd := exec.Command("make", "start", "-C", app.dir(), "ADDR="+dbURL.String(), "PORT="+h.baseURL.Port())
 	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
 
@@ -136,21 +181,29 @@ func initORMApp(app application, dbURL *url.URL) (func() error, error) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	killCmd := func() error {
-		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		// Ask the application to shut down gracefully first. make does not
+		// forward signals to the application, so signal the process group.
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil {
 			return err
 		}
-		// This error is expected.
-		if err := cmd.Wait(); err.Error() != "signal: "+syscall.SIGKILL.String() {
+		// make exits because of the signal, so an exit error is expected.
+		var exitErr *exec.ExitError
+		if err := cmd.Wait(); err != nil && !errors.As(err, &exitErr) {
 			return err
 		}
 
-		// Killing a process is not instant. For example, with the Hibernate server,
-		// it often takes ~10 seconds for the listen port to become available after
-		// this function is called. This is despite the above code that issues a
-		// SIGKILL to the process group for the test server.
-		for {
-			if !(apiHandler{}).canDial() {
-				break
+		// Wait for the application to release the listen port. Applications
+		// that do not handle SIGTERM, or that take too long to drain, are
+		// killed. Even after a SIGKILL, releasing the port is not instant. For
+		// example, with the Hibernate server, it often takes ~10 seconds for
+		// the listen port to become available.
+		const maxShutdownWait = 15 * time.Second
+		for waited := time.Duration(0); h.canDial(); waited += time.Second {
+			if waited == maxShutdownWait {
+				log.Printf("app server did not shut down after SIGTERM, sending SIGKILL")
+				if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+					return err
+				}
 			}
 			log.Printf("waiting for app server port to become available")
 			time.Sleep(time.Second)
@@ -170,7 +223,7 @@ func initORMApp(app application, dbURL *url.URL) (func() error, error) {
 		if processState := cmd.ProcessState; processState != nil && processState.Exited() {
 			return nil, fmt.Errorf("command %s exited: %v", cmd.Args, cmd.Wait())
 		}
-		if err := (apiHandler{}).ping(app.name()); err != nil {
+		if err := h.ready(app.name()); err != nil {
 			if waited > maxWait {
 				if err := killCmd(); err != nil {
 					log.Printf("failed to kill command %s with PID %d: %s", cmd.Args, cmd.ProcessState.Pid(), err)
@@ -228,19 +281,44 @@ type testInfo struct {
 	language, orm string
 	tableNames    testTableNames  // defaults to defaultTestTableNames
 	columnNames   testColumnNames // defaults to defaultTestColumnNames
+	schema        testSchema      // defaults to defaultTestSchema
+	// cancelsStatements is set for apps that cancel the database statements of
+	// requests that are abandoned by the client.
+	cancelsStatements bool
+	// routes describes how the app serves the routes that address single
+	// objects. The tests of the routes that it does not serve are skipped.
+	routes routeSupport
+	// loadsOrderRelations is set for apps that return orders along with their
+	// customer and products. The relations that other apps do not return are
+	// only reported.
+	loadsOrderRelations bool
 }
 
-func testORM(t *testing.T, info testInfo, auth authMode) {
+// withDefaults returns the info with the defaults of the fields that are not
+// set filled in.
+func (info testInfo) withDefaults() testInfo {
 	if info.tableNames == (testTableNames{}) {
 		info.tableNames = defaultTestTableNames
 	}
 	if info.columnNames.IsEmpty() {
 		info.columnNames = defaultTestColumnNames
 	}
-	app := application{
+	if info.schema.IsEmpty() {
+		info.schema = defaultTestSchema
+	}
+	return info
+}
+
+func (info testInfo) app() application {
+	return application{
 		language: info.language,
 		orm:      info.orm,
 	}
+}
+
+func testORM(t *testing.T, info testInfo, auth authMode) {
+	info = info.withDefaults()
+	app := info.app()
 
 	type testCase struct {
 		name  string
@@ -299,12 +377,20 @@ func testORM(t *testing.T, info testInfo, auth authMode) {
 			td := testDriver{
 				db:          tc.db,
 				dbName:      app.dbName(),
+				api:         newAPIHandler("http://" + freeAddr(t)),
 				tableNames:  info.tableNames,
 				columnNames: info.columnNames,
+				schema:      info.schema,
+
+				loadsOrderRelations: info.loadsOrderRelations,
+				routes:              info.routes,
+			}
+			if !info.loadsOrderRelations {
+				t.Cleanup(apiContract.allowMissingOrderRelations(td.api.addr()))
 			}
 
 			t.Run("FirstRun", func(t *testing.T) {
-				stopApp, err := initORMApp(app, tc.dbURL)
+				stopApp, err := launchApp(*launcher, app, tc.dbURL, td.api)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -325,6 +411,14 @@ func testORM(t *testing.T, info testInfo, auth authMode) {
 					"OrderProductsTable": td.TestGeneratedOrderProductsTableColumns,
 				}.T)
 
+				// Test the types, constraints and indexes of those tables.
+				t.Run("GeneratedSchema", parallelTestGroup{
+					"CustomersTable":     td.TestCustomersTableSchema,
+					"ProductsTable":      td.TestProductsTableSchema,
+					"OrdersTable":        td.TestOrdersTableSchema,
+					"OrderProductsTable": td.TestOrderProductsTableSchema,
+				}.T)
+
 				// Test that the tables begin empty.
 				t.Run("EmptyTables", parallelTestGroup{
 					"CustomersTable":     td.TestCustomersEmpty,
@@ -344,6 +438,11 @@ func testORM(t *testing.T, info testInfo, auth authMode) {
 				t.Run("CreateCustomer", td.TestCreateCustomer)
 				t.Run("CreateProduct", td.TestCreateProduct)
 
+				// Test that abandoned requests do not leave statements running.
+				if info.cancelsStatements {
+					t.Run("CancelRequest", td.TestCancelledRequestCancelsStatement)
+				}
+
 				// Test that the API returns what we just created.
 				t.Run("RetrieveFromAPIAfterInitialCreation", parallelTestGroup{
 					"Customers": td.TestRetrieveCustomerAfterCreation,
@@ -355,12 +454,44 @@ func testORM(t *testing.T, info testInfo, auth authMode) {
 
 				// Test that the API returns what we just created.
 				t.Run("RetrieveFromAPIAfterDependentCreation", parallelTestGroup{
-					"Order": td.TestRetrieveProductAfterCreation,
+					"Order": td.TestRetrieveOrderAfterCreation,
+				}.T)
+
+				// Test the remaining routes of the API, which skip the routes that
+				// the app does not serve. Objects that these tests create are
+				// deleted, and objects that they modify are restored, so that the
+				// second run finds what was created above.
+				t.Run("RetrieveByIDFromAPI", parallelTestGroup{
+					"Customer": td.TestRetrieveCustomerByID,
+					"Product":  td.TestRetrieveProductByID,
+					"Order":    td.TestRetrieveOrderByID,
 				}.T)
+				t.Run("MissingIDs", parallelTestGroup{
+					"Customer": td.TestMissingCustomer,
+					"Product":  td.TestMissingProduct,
+					"Order":    td.TestMissingOrder,
+				}.T)
+				t.Run("MalformedIDs", td.TestMalformedIDs)
+				t.Run("UpdateCustomer", td.TestUpdateCustomer)
+				t.Run("UpdateProduct", td.TestUpdateProduct)
+				t.Run("UpdateOrder", td.TestUpdateOrder)
+				t.Run("AddProductToOrder", td.TestAddProductToOrder)
+				t.Run("DeleteOrder", td.TestDeleteOrder)
+				t.Run("DeleteProduct", td.TestDeleteProduct)
+
+				// Test that the responses above conform to the REST API's contract.
+				t.Run("Contract", td.TestResponsesMatchContract)
 			})
 
+			// Record the schema that the first run generated, for the report.
+			if *schemaReportPath != "" {
+				t.Run("DumpSchema", func(t *testing.T) {
+					generatedSchemas.add(app.name(), td.dumpSchema(t))
+				})
+			}
+
 			t.Run("SecondRun", func(t *testing.T) {
-				stopApp, err := initORMApp(app, tc.dbURL)
+				stopApp, err := launchApp(*launcher, app, tc.dbURL, td.api)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -374,13 +505,18 @@ func testORM(t *testing.T, info testInfo, auth authMode) {
 				t.Run("RetrieveFromAPIAfterRestart", parallelTestGroup{
 					"Customers": td.TestRetrieveCustomerAfterCreation,
 					"Products":  td.TestRetrieveProductAfterCreation,
-					"Order":     td.TestRetrieveProductAfterCreation,
+					"Order":     td.TestRetrieveOrderAfterCreation,
 				}.T)
+
+				t.Run("Contract", td.TestResponsesMatchContract)
 			})
 		})
 	}
 }
 
+// testORMForAuthModesExcept tests an ORM application with each auth mode in
+// turn. Tests of different applications can run in parallel, since each
+// application gets its own database server and listens on its own port.
 func testORMForAuthModesExcept(t *testing.T, info testInfo, skips map[authMode]string /* mode -> reason */) {
 	for auth := authMode(0); auth < authModeSentinel; auth++ {
 		t.Run(fmt.Sprint(auth), func(t *testing.T) {
@@ -394,13 +530,98 @@ func testORMForAuthModesExcept(t *testing.T, info testInfo, skips map[authMode]s
 
 func nothingSkipped() map[authMode]string { return nil }
 
+// The applications that are tested, with what the tests need to know about
+// them.
+var (
+	gormTestInfo = testInfo{language: "go", orm: "gorm", cancelsStatements: true, loadsOrderRelations: true}
+	gopgTestInfo = testInfo{language: "go", orm: "gopg", schema: gopgTestSchema, cancelsStatements: true, loadsOrderRelations: true}
+
+	hibernateTestInfo = testInfo{
+		language: "java",
+		orm:      "hibernate",
+		schema:   hibernateTestSchema,
+		routes: routeSupport{
+			unserved: skipRoutes("updates are merged outside of a transaction, so they are never flushed",
+				updateCustomerRoute, updateProductRoute, updateOrderRoute).
+				and(skipRoutes("it first deletes orders by a column that the orders table does not have",
+					deleteProductRoute, deleteOrderRoute)).
+				and(skipRoutes("not implemented", addProductToOrderRoute)),
+			malformedIDs: skipRoutes("JAX-RS responds with 404 to IDs that are not integers",
+				getCustomerRoute, deleteCustomerRoute, getProductRoute, getOrderRoute),
+		},
+	}
+	sequelizeTestInfo = testInfo{
+		language: "node",
+		orm:      "sequelize",
+		schema:   sequelizeTestSchema,
+		routes: routeSupport{
+			unserved: skipRoutes("not implemented",
+				updateCustomerRoute, deleteCustomerRoute, updateProductRoute, deleteProductRoute,
+				updateOrderRoute, deleteOrderRoute, addProductToOrderRoute),
+			missingIDs: skipRoutes("objects that are not found fail to serialize, with a 500",
+				getCustomerRoute, getProductRoute, getOrderRoute),
+			malformedIDs: skipRoutes("IDs are parsed into NaN, which is not found",
+				getCustomerRoute, getProductRoute, getOrderRoute),
+		},
+	}
+	sqlalchemyTestInfo = testInfo{
+		language: "python",
+		orm:      "sqlalchemy",
+		schema:   sqlalchemyTestSchema,
+		routes: routeSupport{
+			unserved: skipRoutes("not implemented",
+				updateCustomerRoute, deleteCustomerRoute, updateProductRoute, deleteProductRoute,
+				updateOrderRoute, deleteOrderRoute, addProductToOrderRoute),
+			missingIDs: skipRoutes("objects that are not found fail to serialize, with a 500",
+				getCustomerRoute, getProductRoute, getOrderRoute),
+			malformedIDs: skipRoutes("IDs that are not integers fail to parse, with a 500",
+				getCustomerRoute, getProductRoute, getOrderRoute),
+		},
+	}
+	djangoTestInfo = testInfo{
+		language:    "python",
+		orm:         "django",
+		tableNames:  djangoTestTableNames,
+		columnNames: djangoTestColumnNames,
+		schema:      djangoTestSchema,
+		routes: routeSupport{
+			// url() takes regular expressions, so the routes with an <int:id>
+			// never match, and the collections serve every path below them.
+			unserved: skipRoutes("the collection routes match the paths of single objects",
+				getCustomerRoute, updateCustomerRoute, deleteCustomerRoute,
+				getProductRoute, updateProductRoute, deleteProductRoute,
+				getOrderRoute, updateOrderRoute, deleteOrderRoute, addProductToOrderRoute),
+		},
+	}
+	activerecordTestInfo = testInfo{
+		language: "ruby",
+		orm:      "activerecord",
+		schema:   activerecordTestSchema,
+		routes: routeSupport{
+			unserved: skipRoutes("updates respond with 204 No Content, without the updated object",
+				updateCustomerRoute, updateProductRoute, updateOrderRoute).
+				and(skipRoutes("the controller refers to undefined variables, so it fails",
+					deleteOrderRoute, addProductToOrderRoute)),
+			malformedIDs: skipRoutes("IDs that are not integers are not found, with a 404",
+				getCustomerRoute, deleteCustomerRoute, getProductRoute, deleteProductRoute, getOrderRoute),
+		},
+	}
+
+	allTestInfos = []testInfo{
+		gormTestInfo, gopgTestInfo, hibernateTestInfo, sequelizeTestInfo,
+		sqlalchemyTestInfo, djangoTestInfo, activerecordTestInfo,
+	}
+)
+
 func TestGORM(t *testing.T) {
-	testORMForAuthModesExcept(t, testInfo{language: "go", orm: "gorm"}, nothingSkipped())
+	t.Parallel()
+	testORMForAuthModesExcept(t, gormTestInfo, nothingSkipped())
 }
 
 func TestGOPG(t *testing.T) {
+	t.Parallel()
 	testORMForAuthModesExcept(t,
-		testInfo{language: "go", orm: "gopg"},
+		gopgTestInfo,
 		map[authMode]string{
 			// https://github.com/go-pg/pg/blob/v10/options.go
 			// If we set up a secure deployment and went through the proxy, it would work (or should anyway), but only
@@ -413,29 +634,110 @@ func TestGOPG(t *testing.T) {
 }
 
 func TestHibernate(t *testing.T) {
-	testORMForAuthModesExcept(t, testInfo{language: "java", orm: "hibernate"}, nothingSkipped())
+	t.Parallel()
+	testORMForAuthModesExcept(t, hibernateTestInfo, nothingSkipped())
 }
 
 func TestSequelize(t *testing.T) {
-	testORMForAuthModesExcept(t, testInfo{language: "node", orm: "sequelize"}, nothingSkipped())
+	t.Parallel()
+	testORMForAuthModesExcept(t, sequelizeTestInfo, nothingSkipped())
 }
 
 func TestSQLAlchemy(t *testing.T) {
-	testORMForAuthModesExcept(t, testInfo{language: "python", orm: "sqlalchemy"}, nothingSkipped())
+	t.Parallel()
+	testORMForAuthModesExcept(t, sqlalchemyTestInfo, nothingSkipped())
 }
 
 func TestDjango(t *testing.T) {
-	testORMForAuthModesExcept(
-		t,
-		testInfo{
-			language:    "python",
-			orm:         "django",
-			tableNames:  djangoTestTableNames,
-			columnNames: djangoTestColumnNames,
-		}, nothingSkipped(),
-	)
+	t.Parallel()
+	testORMForAuthModesExcept(t, djangoTestInfo, nothingSkipped())
 }
 
 func TestActiveRecord(t *testing.T) {
-	testORMForAuthModesExcept(t, testInfo{language: "ruby", orm: "activerecord"}, nothingSkipped())
+	t.Parallel()
+	testORMForAuthModesExcept(t, activerecordTestInfo, nothingSkipped())
+}
+
+// TestDifferential replays the same pseudo-random sequence of API operations,
+// valid and invalid ones, against every application, each with a database of
+// its own, and reports the first operation after which the response status,
+// response body or tables of an application differ from those of the majority
+// of applications. It only runs with -differential-steps.
+func TestDifferential(t *testing.T) {
+	if *differentialSteps <= 0 {
+		t.Skip("-differential-steps is not set")
+	}
+	var infos []testInfo
+	for _, info := range allTestInfos {
+		if *differentialApps == "" || strings.Contains(","+*differentialApps+",", ","+info.app().name()+",") {
+			infos = append(infos, info.withDefaults())
+		}
+	}
+	ops := generateOps(*differentialSeed, *differentialSteps)
+
+	// An application's replay can stop early, when its tables cannot be
+	// read, and its partial results are left out of the comparison.
+	results := make([][]diffResult, len(infos))
+	started := make([]bool, len(infos))
+	replayed := make([]bool, len(infos))
+	t.Run("Replay", func(t *testing.T) {
+		for i, info := range infos {
+			i, info := i, info
+			app := info.app()
+			t.Run(app.name(), func(t *testing.T) {
+				t.Parallel()
+				ts := newServer(t, authInsecure)
+				db, dbURL, stopDB := startServerWithApplication(t, ts, app)
+				defer stopDB()
+				if info, ok := minRequiredVersionsByORMName[app.orm]; ok {
+					if !getVersionFromDB(t, db).AtLeast(info.v) {
+						t.Skip(info.skipMsg)
+					}
+				}
+
+				td := testDriver{
+					db:          db,
+					dbName:      app.dbName(),
+					api:         newAPIHandler("http://" + freeAddr(t)),
+					tableNames:  info.tableNames,
+					columnNames: info.columnNames,
+				}
+				stopApp, err := launchApp(*launcher, app, dbURL, td.api)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() {
+					if err := stopApp(); err != nil {
+						t.Fatal(err)
+					}
+				}()
+
+				started[i] = true
+				r := newDiffReplayer(td)
+				for _, op := range ops {
+					results[i] = append(results[i], r.apply(t, op))
+				}
+				replayed[i] = true
+			})
+		}
+	})
+
+	var apps []string
+	var appResults [][]diffResult
+	for i, info := range infos {
+		switch {
+		case replayed[i]:
+			apps = append(apps, info.app().name())
+			appResults = append(appResults, results[i])
+		case started[i]:
+			t.Errorf("the replay of %s stopped after %d of %d operations, and is not compared",
+				info.app().name(), len(results[i]), len(ops))
+		}
+	}
+	if len(apps) < 2 {
+		t.Skipf("only %d applications replayed the operations", len(apps))
+	}
+	if d := firstDivergence(apps, len(ops), appResults); d != nil {
+		t.Errorf("applications diverge with -differential-seed=%d:\n%s", *differentialSeed, d.report(ops))
+	}
 }
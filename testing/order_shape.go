@@ -0,0 +1,153 @@
+package testing
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/cockroachdb/examples-orms/go/client"
+)
+
+// orderRelations are the relations of an order that the REST API's contract
+// expects to be returned along with it: the customer that placed the order,
+// that customer's name, and the products of the order.
+var orderRelations = []string{"customer", "customer.name", "products"}
+
+// orderShape is an order with the IDs that the database generated replaced by
+// the names of the objects that they refer to, so that orders can be compared
+// regardless of their IDs. The relations that an application does not return
+// are left empty.
+type orderShape struct {
+	Subtotal float64
+	Customer string
+	Products []string
+}
+
+// withoutRelations returns the shape with the given relations left empty.
+func (s orderShape) withoutRelations(relations []string) orderShape {
+	for _, relation := range relations {
+		switch relation {
+		case "customer":
+			s.Customer = ""
+		case "products":
+			s.Products = nil
+		}
+	}
+	return s
+}
+
+// orderShapes returns the shapes of orders returned by the API, along with the
+// relations that are missing from any of them. The test fails if an order
+// refers to other objects than its rows in the database do, or embeds objects
+// that differ from their rows, so that comparing shapes still checks the
+// relationships between objects.
+func (td testDriver) orderShapes(t *testing.T, orders []client.Order) ([]orderShape, []string) {
+	customers := make(map[int]client.Customer)
+	for _, c := range td.queryCustomerRows(t) {
+		customers[c.ID] = c
+	}
+	products := make(map[int]client.Product)
+	for _, p := range td.queryProductRows(t) {
+		products[p.ID] = p
+	}
+
+	shapes := make([]orderShape, len(orders))
+	missing := make(map[string]bool)
+	for i, o := range orders {
+		shapes[i].Subtotal = o.Subtotal
+
+		var customerID int
+		if err := td.db.QueryRow(fmt.Sprintf(`SELECT customer_id FROM %s WHERE id = $1`,
+			td.tableNames.ordersTable), o.ID).Scan(&customerID); err != nil {
+			t.Fatalf("error querying the customer of order %d: %v", o.ID, err)
+		}
+		switch {
+		case o.Customer.ID == 0:
+			missing["customer"] = true
+		case o.Customer.ID != customerID:
+			t.Errorf("order %d refers to customer %d, but its row refers to customer %d",
+				o.ID, o.Customer.ID, customerID)
+		default:
+			shapes[i].Customer = customers[customerID].Name
+			if o.Customer.Name == "" {
+				missing["customer.name"] = true
+			} else if o.Customer != customers[customerID] {
+				t.Errorf("order %d embeds customer %+v, but its row is %+v",
+					o.ID, o.Customer, customers[customerID])
+			}
+		}
+
+		if o.Products == nil {
+			missing["products"] = true
+			continue
+		}
+		productIDs := td.orderProductIDs(t, o.ID)
+		var foundIDs []int
+		shapes[i].Products = []string{}
+		for _, p := range o.Products {
+			foundIDs = append(foundIDs, p.ID)
+			shapes[i].Products = append(shapes[i].Products, p.Name)
+			if p != products[p.ID] {
+				t.Errorf("order %d embeds product %+v, but its row is %+v", o.ID, p, products[p.ID])
+			}
+		}
+		sort.Ints(foundIDs)
+		sort.Strings(shapes[i].Products)
+		if fmt.Sprint(foundIDs) != fmt.Sprint(productIDs) {
+			t.Errorf("order %d refers to products %v, but its rows refer to products %v",
+				o.ID, foundIDs, productIDs)
+		}
+	}
+
+	var missingRelations []string
+	for _, relation := range orderRelations {
+		if missing[relation] {
+			missingRelations = append(missingRelations, relation)
+		}
+	}
+	return shapes, missingRelations
+}
+
+// queryCustomerRows returns the customers in the database.
+func (td testDriver) queryCustomerRows(t *testing.T) []client.Customer {
+	rows, err := td.db.Query(fmt.Sprintf(`SELECT id, name FROM %s`, td.tableNames.customersTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var customers []client.Customer
+	for rows.Next() {
+		var c client.Customer
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			t.Fatal(err)
+		}
+		customers = append(customers, c)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return customers
+}
+
+// queryProductRows returns the products in the database.
+func (td testDriver) queryProductRows(t *testing.T) []client.Product {
+	rows, err := td.db.Query(fmt.Sprintf(`SELECT id, name, price::FLOAT8 FROM %s`, td.tableNames.productsTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var products []client.Product
+	for rows.Next() {
+		var p client.Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Price); err != nil {
+			t.Fatal(err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return products
+}
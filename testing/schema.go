@@ -0,0 +1,330 @@
+package testing
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testTableSchema holds the expected definition of a table, in the format in
+// which testDriver reads it from the database. Every list is sorted.
+type testTableSchema struct {
+	// columns are "name type", followed by " NOT NULL" if the column cannot
+	// be NULL. The type includes the length of strings and the precision and
+	// scale of decimals, if they are limited.
+	columns []string
+	// primaryKey holds the columns of the primary key, separated by ", ".
+	// Tables without an explicit primary key have the hidden "rowid" one.
+	primaryKey string
+	// uniques holds the columns of each UNIQUE constraint.
+	uniques []string
+	// foreignKeys are "columns REFERENCES table ON DELETE action".
+	foreignKeys []string
+	// indexes holds the columns of each secondary index that is not unique.
+	// The indexes that CockroachDB creates for foreign keys are ignored.
+	indexes []string
+}
+
+type testSchema struct {
+	customersTable     testTableSchema
+	ordersTable        testTableSchema
+	productsTable      testTableSchema
+	orderProductsTable testTableSchema
+}
+
+func (ts testSchema) IsEmpty() bool {
+	return reflect.DeepEqual(ts, testSchema{})
+}
+
+// The schemas that each ORM is expected to generate. The differences between
+// them are what the tests document: for example, only some ORMs make the
+// name of a product unique or index the foreign keys of orders.
+var (
+	defaultTestSchema = testSchema{
+		customersTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name text NOT NULL"},
+			primaryKey: "id",
+		},
+		ordersTable: testTableSchema{
+			columns:     []string{"customer_id bigint", "id bigint NOT NULL", "subtotal numeric(18,2)"},
+			primaryKey:  "id",
+			foreignKeys: []string{"customer_id REFERENCES customers ON DELETE NO ACTION"},
+		},
+		productsTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name text NOT NULL", "price numeric(18,2)"},
+			primaryKey: "id",
+			uniques:    []string{"name"},
+		},
+		orderProductsTable: testTableSchema{
+			columns:    []string{"order_id bigint NOT NULL", "product_id bigint NOT NULL"},
+			primaryKey: "order_id, product_id",
+			foreignKeys: []string{
+				"order_id REFERENCES orders ON DELETE NO ACTION",
+				"product_id REFERENCES products ON DELETE NO ACTION",
+			},
+		},
+	}
+
+	gopgTestSchema = testSchema{
+		customersTable: defaultTestSchema.customersTable,
+		ordersTable:    defaultTestSchema.ordersTable,
+		productsTable:  defaultTestSchema.productsTable,
+		orderProductsTable: testTableSchema{
+			columns:    []string{"order_id bigint", "product_id bigint"},
+			primaryKey: "rowid",
+			foreignKeys: []string{
+				"order_id REFERENCES orders ON DELETE CASCADE",
+				"product_id REFERENCES products ON DELETE NO ACTION",
+			},
+		},
+	}
+
+	hibernateTestSchema = testSchema{
+		customersTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying(255)"},
+			primaryKey: "id",
+			uniques:    []string{"id"},
+		},
+		ordersTable: testTableSchema{
+			columns:     []string{"customer_id bigint", "id bigint NOT NULL", "subtotal numeric(18,2)"},
+			primaryKey:  "id",
+			uniques:     []string{"id"},
+			foreignKeys: []string{"customer_id REFERENCES customers ON DELETE NO ACTION"},
+		},
+		productsTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying(255)", "price numeric(18,2)"},
+			primaryKey: "id",
+			uniques:    []string{"id"},
+		},
+		orderProductsTable: defaultTestSchema.orderProductsTable,
+	}
+
+	sequelizeTestSchema = testSchema{
+		customersTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying(255)"},
+			primaryKey: "id",
+		},
+		ordersTable: testTableSchema{
+			columns:    []string{"customer_id bigint", "id bigint NOT NULL", "subtotal numeric(18,2)"},
+			primaryKey: "id",
+		},
+		productsTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying(255)", "price numeric(18,2)"},
+			primaryKey: "id",
+		},
+		orderProductsTable: testTableSchema{
+			columns:    []string{"order_id bigint NOT NULL", "product_id bigint NOT NULL"},
+			primaryKey: "order_id, product_id",
+		},
+	}
+
+	sqlalchemyTestSchema = testSchema{
+		customersTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying"},
+			primaryKey: "id",
+		},
+		ordersTable: defaultTestSchema.ordersTable,
+		productsTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying NOT NULL", "price numeric(18,2)"},
+			primaryKey: "id",
+			uniques:    []string{"name"},
+		},
+		orderProductsTable: testTableSchema{
+			columns:     []string{"order_id bigint", "product_id bigint"},
+			primaryKey:  "rowid",
+			foreignKeys: defaultTestSchema.orderProductsTable.foreignKeys,
+		},
+	}
+
+	djangoTestSchema = testSchema{
+		customersTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying(250) NOT NULL"},
+			primaryKey: "id",
+		},
+		ordersTable: testTableSchema{
+			columns:     []string{"customer_id bigint", "id bigint NOT NULL", "subtotal numeric(18,2) NOT NULL"},
+			primaryKey:  "id",
+			foreignKeys: []string{"customer_id REFERENCES cockroach_example_customers ON DELETE NO ACTION"},
+			indexes:     []string{"customer_id"},
+		},
+		productsTable: testTableSchema{
+			columns: []string{
+				"id bigint NOT NULL", "name character varying(250) NOT NULL", "price numeric(18,2) NOT NULL",
+			},
+			primaryKey: "id",
+		},
+		orderProductsTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "orders_id bigint NOT NULL", "products_id bigint NOT NULL"},
+			primaryKey: "id",
+			uniques:    []string{"orders_id, products_id"},
+			foreignKeys: []string{
+				"orders_id REFERENCES cockroach_example_orders ON DELETE NO ACTION",
+				"products_id REFERENCES cockroach_example_products ON DELETE NO ACTION",
+			},
+			indexes: []string{"orders_id", "products_id"},
+		},
+	}
+
+	activerecordTestSchema = testSchema{
+		customersTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying NOT NULL"},
+			primaryKey: "id",
+		},
+		ordersTable: testTableSchema{
+			columns:     []string{"customer_id bigint NOT NULL", "id bigint NOT NULL", "subtotal numeric(18,2) NOT NULL"},
+			primaryKey:  "id",
+			foreignKeys: defaultTestSchema.ordersTable.foreignKeys,
+			indexes:     []string{"customer_id"},
+		},
+		productsTable: testTableSchema{
+			columns:    []string{"id bigint NOT NULL", "name character varying NOT NULL", "price numeric(18,2) NOT NULL"},
+			primaryKey: "id",
+		},
+		orderProductsTable: testTableSchema{
+			columns:     defaultTestSchema.orderProductsTable.columns,
+			primaryKey:  "rowid",
+			foreignKeys: defaultTestSchema.orderProductsTable.foreignKeys,
+			indexes:     []string{"order_id", "product_id"},
+		},
+	}
+)
+
+func (td testDriver) TestCustomersTableSchema(t *testing.T) {
+	td.testSchemaForTable(t, td.tableNames.customersTable, td.schema.customersTable)
+}
+func (td testDriver) TestOrdersTableSchema(t *testing.T) {
+	td.testSchemaForTable(t, td.tableNames.ordersTable, td.schema.ordersTable)
+}
+func (td testDriver) TestProductsTableSchema(t *testing.T) {
+	td.testSchemaForTable(t, td.tableNames.productsTable, td.schema.productsTable)
+}
+func (td testDriver) TestOrderProductsTableSchema(t *testing.T) {
+	td.testSchemaForTable(t, td.tableNames.orderProductsTable, td.schema.orderProductsTable)
+}
+
+// testSchemaForTable checks the types and nullability of the columns of a
+// table, and its constraints and indexes, in separate subtests so that every
+// difference from the expected schema is reported.
+func (td testDriver) testSchemaForTable(t *testing.T, table string, expected testTableSchema) {
+	t.Run("Columns", func(t *testing.T) {
+		td.queryAndAssert(t, expected.columns, `
+SELECT column_name || ' ' || data_type
+  || COALESCE('(' || character_maximum_length::STRING || ')', '')
+  || CASE WHEN data_type = 'numeric'
+       THEN COALESCE('(' || numeric_precision::STRING || ',' || numeric_scale::STRING || ')', '')
+       ELSE '' END
+  || CASE WHEN is_nullable = 'NO' THEN ' NOT NULL' ELSE '' END
+FROM information_schema.columns
+-- see TestGeneratedTables about supporting both the legacy and the new information_schema structures.
+WHERE ((table_catalog = 'def' AND table_schema = $1) OR (table_catalog = $1 AND table_schema = 'public'))
+  AND table_name = $2
+  AND column_name != 'rowid'
+ORDER BY column_name`, td.dbName, table)
+	})
+
+	t.Run("PrimaryKey", func(t *testing.T) {
+		td.assertGroups(t, []string{expected.primaryKey}, keyConstraintsQuery("PRIMARY KEY"), td.dbName, table)
+	})
+	t.Run("UniqueConstraints", func(t *testing.T) {
+		td.assertGroups(t, expected.uniques, keyConstraintsQuery("UNIQUE"), td.dbName, table)
+	})
+
+	t.Run("ForeignKeys", func(t *testing.T) {
+		td.assertGroups(t, expected.foreignKeys, `
+SELECT rc.constraint_name, kcu.column_name,
+  'REFERENCES ' || rc.referenced_table_name || ' ON DELETE ' || rc.delete_rule
+FROM information_schema.referential_constraints AS rc
+JOIN information_schema.key_column_usage AS kcu
+  ON kcu.constraint_catalog = rc.constraint_catalog
+  AND kcu.constraint_schema = rc.constraint_schema
+  AND kcu.constraint_name = rc.constraint_name
+  AND kcu.table_name = rc.table_name
+WHERE ((rc.constraint_catalog = 'def' AND rc.constraint_schema = $1) OR (rc.constraint_catalog = $1 AND rc.constraint_schema = 'public'))
+  AND rc.table_name = $2
+ORDER BY rc.constraint_name, kcu.ordinal_position`, td.dbName, table)
+	})
+
+	t.Run("Indexes", func(t *testing.T) {
+		td.assertGroups(t, expected.indexes, `
+SELECT index_name, column_name
+FROM information_schema.statistics
+WHERE ((table_catalog = 'def' AND table_schema = $1) OR (table_catalog = $1 AND table_schema = 'public'))
+  AND table_name = $2
+  AND non_unique = 'YES'
+  AND storing = 'NO'
+  AND implicit = 'NO'
+  -- CockroachDB versions before v20.2 index the columns of foreign keys
+  -- automatically.
+  AND index_name NOT LIKE '%auto_index%'
+ORDER BY index_name, seq_in_index`, td.dbName, table)
+	})
+}
+
+// keyConstraintsQuery returns a query for the columns of the constraints of
+// the given type on a table, for assertGroups.
+func keyConstraintsQuery(constraintType string) string {
+	return fmt.Sprintf(`
+SELECT tc.constraint_name, kcu.column_name
+FROM information_schema.table_constraints AS tc
+JOIN information_schema.key_column_usage AS kcu
+  ON kcu.constraint_catalog = tc.constraint_catalog
+  AND kcu.constraint_schema = tc.constraint_schema
+  AND kcu.constraint_name = tc.constraint_name
+  AND kcu.table_name = tc.table_name
+WHERE ((tc.table_catalog = 'def' AND tc.table_schema = $1) OR (tc.table_catalog = $1 AND tc.table_schema = 'public'))
+  AND tc.table_name = $2
+  AND tc.constraint_type = '%s'
+ORDER BY tc.constraint_name, kcu.ordinal_position`, constraintType)
+}
+
+// assertGroups asserts the groups of rows of a query whose rows are a group
+// name, such as the name of a constraint, a column name and, optionally, a
+// description of the group. Each group is described by its columns separated
+// by ", ", followed by its description, and the groups are sorted.
+func (td testDriver) assertGroups(t *testing.T, expected []string, query string, args ...interface{}) {
+	rows, err := td.db.Query(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	columns := make(map[string][]string)
+	descriptions := make(map[string]string)
+	vals := make([]string, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		name := vals[0]
+		if _, ok := columns[name]; !ok {
+			names = append(names, name)
+		}
+		columns[name] = append(columns[name], vals[1])
+		if len(vals) > 2 {
+			descriptions[name] = " " + vals[2]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var found []string
+	for _, name := range names {
+		found = append(found, strings.Join(columns[name], ", ")+descriptions[name])
+	}
+	sort.Strings(found)
+	if !reflect.DeepEqual(expected, found) {
+		t.Fatalf("expecting groups for query %q with args %+v to be %v, found %v", query, args, expected, found)
+	}
+}
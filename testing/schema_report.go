@@ -0,0 +1,313 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// schemaReport collects the schemas that the applications generate, to
+// report how they differ side by side.
+type schemaReport struct {
+	mu      sync.Mutex
+	schemas map[string]normalizedSchema // by application name
+}
+
+// generatedSchemas collects the schemas for the -schema-report flag.
+var generatedSchemas = &schemaReport{schemas: make(map[string]normalizedSchema)}
+
+// normalizedSchema holds the definitions of the tables of a schema, by the
+// name that the tables have in defaultTestTableNames.
+type normalizedSchema map[string][]schemaLine
+
+// schemaLine is a line of the definition of a table. Columns are keyed by
+// their name, and their type and modifiers are the value. Constraints and
+// indexes are keyed by their definition, and have no value.
+type schemaLine struct {
+	key, value string
+}
+
+// add records the schema of an application, unless one was already recorded
+// for it by another test case.
+func (r *schemaReport) add(app string, schema normalizedSchema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.schemas[app]; !ok {
+		r.schemas[app] = schema
+	}
+}
+
+// dumpSchema dumps the schema of the application's database with SHOW
+// CREATE ALL TABLES, for the schema report.
+func (td testDriver) dumpSchema(t *testing.T) normalizedSchema {
+	rows, err := td.db.Query(`SHOW CREATE ALL TABLES`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := rowsToStringSlice(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return normalizeSchema(statements, td.tableNames)
+}
+
+var (
+	createTableRE    = regexp.MustCompile(`(?s)^CREATE TABLE (\S+) \((.*)\)`)
+	addConstraintRE  = regexp.MustCompile(`(?s)^ALTER TABLE (\S+) ADD (CONSTRAINT .*)`)
+	constraintNameRE = regexp.MustCompile(`^CONSTRAINT \S+ `)
+	indexNameRE      = regexp.MustCompile(`^((?:UNIQUE |INVERTED )?INDEX) \S+ `)
+	ascRE            = regexp.MustCompile(` ASC\b`)
+)
+
+// normalizeSchema parses the statements of SHOW CREATE ALL TABLES into the
+// definitions of the tables of the sample applications, so that the schemas of
+// different applications can be compared line by line: tables are renamed to
+// their names in defaultTestTableNames, the foreign keys that are added by
+// ALTER TABLE are moved into the definitions of their tables, and the names of
+// constraints and indexes, which every ORM chooses differently, are left out.
+// Column families are left out too.
+func normalizeSchema(statements []string, tableNames testTableNames) normalizedSchema {
+	canonical := map[string]string{
+		tableNames.customersTable:     defaultTestTableNames.customersTable,
+		tableNames.ordersTable:        defaultTestTableNames.ordersTable,
+		tableNames.productsTable:      defaultTestTableNames.productsTable,
+		tableNames.orderProductsTable: defaultTestTableNames.orderProductsTable,
+	}
+	type renaming struct {
+		re   *regexp.Regexp
+		name string
+	}
+	var renamings []renaming
+	for name, canonicalName := range canonical {
+		renamings = append(renamings, renaming{
+			re:   regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`),
+			name: canonicalName,
+		})
+	}
+	rename := func(s string) string {
+		s = strings.ReplaceAll(s, "public.", "")
+		for _, r := range renamings {
+			s = r.re.ReplaceAllString(s, r.name)
+		}
+		return s
+	}
+
+	schema := make(normalizedSchema)
+	add := func(table, line string) {
+		line = constraintNameRE.ReplaceAllString(line, "")
+		line = indexNameRE.ReplaceAllString(line, "$1 ")
+		line = ascRE.ReplaceAllString(line, "")
+		fields := strings.SplitN(line, " ", 2)
+		switch fields[0] {
+		case "FAMILY":
+			return
+		case "CHECK", "FOREIGN", "INDEX", "INVERTED", "PRIMARY", "UNIQUE":
+			schema[table] = append(schema[table], schemaLine{key: line})
+		default:
+			col := schemaLine{key: fields[0]}
+			if len(fields) > 1 {
+				col.value = fields[1]
+			}
+			schema[table] = append(schema[table], col)
+		}
+	}
+
+	for _, stmt := range statements {
+		stmt = rename(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+		if m := createTableRE.FindStringSubmatch(stmt); m != nil {
+			if !isSampleTable(m[1]) {
+				continue
+			}
+			for _, line := range strings.Split(m[2], "\n") {
+				if line = strings.TrimSuffix(strings.TrimSpace(line), ","); line != "" {
+					add(m[1], line)
+				}
+			}
+		} else if m := addConstraintRE.FindStringSubmatch(stmt); m != nil {
+			if isSampleTable(m[1]) {
+				add(m[1], m[2])
+			}
+		}
+	}
+	return schema
+}
+
+// isSampleTable reports whether a table has one of the names in
+// defaultTestTableNames.
+func isSampleTable(name string) bool {
+	switch name {
+	case defaultTestTableNames.customersTable,
+		defaultTestTableNames.ordersTable,
+		defaultTestTableNames.productsTable,
+		defaultTestTableNames.orderProductsTable:
+		return true
+	default:
+		return false
+	}
+}
+
+// schemaDiff is the side-by-side comparison of the schemas of a report.
+type schemaDiff struct {
+	Apps   []string
+	Tables []tableDiff
+}
+
+type tableDiff struct {
+	Name string
+	Rows []schemaDiffRow
+}
+
+// schemaDiffRow compares a column, constraint or index of a table across
+// applications. Cells hold the value of the line in the schema of each
+// application: the type of a column, "✓" for constraints and indexes, or ""
+// if the application's schema does not have the line.
+type schemaDiffRow struct {
+	Key     string
+	Cells   []string
+	Differs bool
+}
+
+// diff compares the recorded schemas, in the order of the application names.
+// Columns come first in each table, followed by constraints and indexes.
+func (r *schemaReport) diff() schemaDiff {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var d schemaDiff
+	for app := range r.schemas {
+		d.Apps = append(d.Apps, app)
+	}
+	sort.Strings(d.Apps)
+
+	for _, table := range []string{
+		defaultTestTableNames.customersTable,
+		defaultTestTableNames.ordersTable,
+		defaultTestTableNames.productsTable,
+		defaultTestTableNames.orderProductsTable,
+	} {
+		type rowKey struct {
+			isColumn bool
+			key      string
+		}
+		cells := make(map[rowKey][]string)
+		var keys []rowKey
+		for i, app := range d.Apps {
+			for _, line := range r.schemas[app][table] {
+				k := rowKey{isColumn: line.value != "", key: line.key}
+				if _, ok := cells[k]; !ok {
+					cells[k] = make([]string, len(d.Apps))
+					keys = append(keys, k)
+				}
+				cells[k][i] = line.value
+				if !k.isColumn {
+					cells[k][i] = "✓"
+				}
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].isColumn != keys[j].isColumn {
+				return keys[i].isColumn
+			}
+			return keys[i].key < keys[j].key
+		})
+
+		tableDiff := tableDiff{Name: table}
+		for _, k := range keys {
+			row := schemaDiffRow{Key: k.key, Cells: cells[k]}
+			for _, cell := range row.Cells {
+				row.Differs = row.Differs || cell != row.Cells[0]
+			}
+			tableDiff.Rows = append(tableDiff.Rows, row)
+		}
+		d.Tables = append(d.Tables, tableDiff)
+	}
+	return d
+}
+
+const schemaReportIntro = `Schemas generated by the sample applications, from SHOW CREATE ALL TABLES
+after their first run. Tables are named as in the default schema, and the
+names of constraints and indexes are left out. Rows marked with ≠ differ
+between applications; an empty cell means that the application's schema does
+not have the column, constraint or index.`
+
+// write writes the report to path, as HTML if the path ends with .html and as
+// Markdown otherwise.
+func (r *schemaReport) write(path string) error {
+	d := r.diff()
+	var buf bytes.Buffer
+	if ext := filepath.Ext(path); ext == ".html" || ext == ".htm" {
+		if err := schemaReportHTML.Execute(&buf, d); err != nil {
+			return err
+		}
+	} else {
+		writeSchemaReportMarkdown(&buf, d)
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func writeSchemaReportMarkdown(buf *bytes.Buffer, d schemaDiff) {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ").Replace
+	fmt.Fprintf(buf, "# Generated schemas\n\n%s\n", schemaReportIntro)
+	for _, table := range d.Tables {
+		fmt.Fprintf(buf, "\n## %s\n\n|   |   |", table.Name)
+		for _, app := range d.Apps {
+			fmt.Fprintf(buf, " %s |", app)
+		}
+		buf.WriteString("\n|---|---|")
+		for range d.Apps {
+			buf.WriteString("---|")
+		}
+		buf.WriteString("\n")
+		for _, row := range table.Rows {
+			marker := " "
+			if row.Differs {
+				marker = "≠"
+			}
+			fmt.Fprintf(buf, "| %s | `%s` |", marker, escape(row.Key))
+			for _, cell := range row.Cells {
+				if cell != "" && cell != "✓" {
+					cell = "`" + escape(cell) + "`"
+				}
+				fmt.Fprintf(buf, " %s |", cell)
+			}
+			buf.WriteString("\n")
+		}
+	}
+}
+
+var schemaReportHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Generated schemas</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; }
+td code { white-space: nowrap; }
+tr.differs { background: #fff3cd; }
+</style>
+</head>
+<body>
+<h1>Generated schemas</h1>
+<p>` + schemaReportIntro + `</p>
+{{- range .Tables}}
+<h2>{{.Name}}</h2>
+<table>
+<tr><th></th><th></th>{{range $.Apps}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr{{if .Differs}} class="differs"{{end}}><td>{{if .Differs}}≠{{end}}</td><td><code>{{.Key}}</code></td>
+{{- range .Cells}}<td>{{if eq . "✓"}}✓{{else if .}}<code>{{.}}</code>{{end}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,102 @@
+package testing
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeSchema(t *testing.T) {
+	statements := []string{
+		`CREATE TABLE public.cockroach_example_customers (
+	id INT8 NOT NULL DEFAULT unique_rowid(),
+	name VARCHAR(250) NOT NULL,
+	CONSTRAINT cockroach_example_customers_pkey PRIMARY KEY (id ASC),
+	FAMILY "primary" (id, name)
+);`,
+		`CREATE TABLE public.cockroach_example_orders_product (
+	id INT8 NOT NULL DEFAULT unique_rowid(),
+	orders_id INT8 NOT NULL,
+	products_id INT8 NOT NULL,
+	CONSTRAINT cockroach_example_orders_product_pkey PRIMARY KEY (id ASC),
+	UNIQUE INDEX cockroach_example_orders_product_orders_id_products_id_key (orders_id ASC, products_id ASC),
+	INDEX cockroach_example_orders_product_orders_id_idx (orders_id DESC)
+);`,
+		`CREATE TABLE public.django_migrations (
+	id INT8 NOT NULL DEFAULT unique_rowid()
+);`,
+		`ALTER TABLE public.cockroach_example_orders_product ADD CONSTRAINT cockroach_example_orders_product_fk FOREIGN KEY (orders_id) REFERENCES public.cockroach_example_orders(id);`,
+		`-- Validate foreign key constraints. These can fail if there was unvalidated data during the SHOW CREATE ALL TABLES`,
+		`ALTER TABLE public.cockroach_example_orders_product VALIDATE CONSTRAINT cockroach_example_orders_product_fk;`,
+	}
+
+	expected := normalizedSchema{
+		"customers": {
+			{key: "id", value: "INT8 NOT NULL DEFAULT unique_rowid()"},
+			{key: "name", value: "VARCHAR(250) NOT NULL"},
+			{key: "PRIMARY KEY (id)"},
+		},
+		"order_products": {
+			{key: "id", value: "INT8 NOT NULL DEFAULT unique_rowid()"},
+			{key: "orders_id", value: "INT8 NOT NULL"},
+			{key: "products_id", value: "INT8 NOT NULL"},
+			{key: "PRIMARY KEY (id)"},
+			{key: "UNIQUE INDEX (orders_id, products_id)"},
+			{key: "INDEX (orders_id DESC)"},
+			{key: "FOREIGN KEY (orders_id) REFERENCES orders(id)"},
+		},
+	}
+	if found := normalizeSchema(statements, djangoTestTableNames); !reflect.DeepEqual(expected, found) {
+		t.Errorf("expected %+v, found %+v", expected, found)
+	}
+}
+
+func TestSchemaReport(t *testing.T) {
+	r := &schemaReport{schemas: make(map[string]normalizedSchema)}
+	r.add("go/gorm", normalizedSchema{
+		"customers": {{key: "id", value: "INT8 NOT NULL"}, {key: "PRIMARY KEY (id)"}},
+	})
+	r.add("python/django", normalizedSchema{
+		"customers": {{key: "id", value: "INT4 NOT NULL"}, {key: "PRIMARY KEY (id)"}},
+	})
+	// Later test cases of an application do not replace its schema.
+	r.add("go/gorm", normalizedSchema{})
+
+	expected := []schemaDiffRow{
+		{Key: "id", Cells: []string{"INT8 NOT NULL", "INT4 NOT NULL"}, Differs: true},
+		{Key: "PRIMARY KEY (id)", Cells: []string{"✓", "✓"}},
+	}
+	d := r.diff()
+	if !reflect.DeepEqual(d.Apps, []string{"go/gorm", "python/django"}) {
+		t.Errorf("unexpected apps %v", d.Apps)
+	}
+	if found := d.Tables[0].Rows; !reflect.DeepEqual(expected, found) {
+		t.Errorf("expected %+v, found %+v", expected, found)
+	}
+
+	var buf bytes.Buffer
+	writeSchemaReportMarkdown(&buf, d)
+	for _, line := range []string{
+		"| ≠ | `id` | `INT8 NOT NULL` | `INT4 NOT NULL` |",
+		"|   | `PRIMARY KEY (id)` | ✓ | ✓ |",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expected Markdown report to contain %q, found:\n%s", line, buf.String())
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "schemas.html")
+	if err := r.write(path); err != nil {
+		t.Fatal(err)
+	}
+	html, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(html, []byte(`<tr class="differs">`)) {
+		t.Errorf("expected HTML report to mark differing rows, found:\n%s", html)
+	}
+}
@@ -2,13 +2,18 @@ package testing
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/cockroachdb/examples-orms/go/gorm/model"
+	"github.com/cockroachdb/examples-orms/go/api"
+	"github.com/cockroachdb/examples-orms/go/client"
 )
 
 type testTableNames struct {
@@ -29,14 +34,20 @@ func (tcn testColumnNames) IsEmpty() bool {
 	return len(tcn.customersColumns)+len(tcn.ordersColumns)+len(tcn.productsColumns)+len(tcn.ordersProductsColumns) == 0
 }
 
-// These need to be variables so that their address can be taken.
+// The values that the tests create, and the schema they expect to find.
 var (
 	customerName1 = "Billy"
 
+	customerName2 = "Bobby"
+
 	productName1       = "Ice Cream"
 	productPrice1      = "123.40"
 	productPrice1Float = 123.40
 
+	productName2       = "Fudge"
+	productPrice2      = "4.50"
+	productPrice2Float = 4.50
+
 	defaultTestTableNames = testTableNames{
 		customersTable:     "customers",
 		ordersTable:        "orders",
@@ -72,6 +83,8 @@ type parallelTestGroup map[string]func(t *testing.T)
 
 func (ptg parallelTestGroup) T(t *testing.T) {
 	for name, f := range ptg {
+		// Each parallel subtest must run its own entry, not the last one.
+		f := f
 		t.Run(name, func(subT *testing.T) {
 			subT.Parallel()
 			f(subT)
@@ -89,6 +102,12 @@ type testDriver struct {
 	tableNames testTableNames
 	// Holds the expected columns for this test.
 	columnNames testColumnNames
+	// Holds the expected schema for this test.
+	schema testSchema
+	// Whether the API is expected to return orders with all their relations.
+	loadsOrderRelations bool
+	// How the API serves the routes that address single objects.
+	routes routeSupport
 }
 
 func (td testDriver) TestGeneratedTables(t *testing.T) {
@@ -168,8 +187,8 @@ func (td testDriver) TestRetrieveCustomersBeforeCreation(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	expected1 := []model.Customer{}
-	var expected2 []model.Customer
+	expected1 := []client.Customer{}
+	var expected2 []client.Customer
 	if !reflect.DeepEqual(expected1, found) && !reflect.DeepEqual(expected2, found) {
 		t.Fatalf("expecting customers from api before creation to be %v or %v, found %v", expected1, expected2, found)
 	}
@@ -180,8 +199,8 @@ func (td testDriver) TestRetrieveProductsBeforeCreation(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	expected1 := []model.Product{}
-	var expected2 []model.Product
+	expected1 := []client.Product{}
+	var expected2 []client.Product
 	if !reflect.DeepEqual(expected1, found) && !reflect.DeepEqual(expected2, found) {
 		t.Fatalf("expecting products from api before creation to be %v or %v, found %v", expected1, expected2, found)
 	}
@@ -192,50 +211,68 @@ func (td testDriver) TestRetrieveOrdersBeforeCreation(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	expected1 := []model.Order{}
-	var expected2 []model.Order
+	expected1 := []client.Order{}
+	var expected2 []client.Order
 	if !reflect.DeepEqual(expected1, found) && !reflect.DeepEqual(expected2, found) {
 		t.Fatalf("expecting orders from api before creation to be %v or %v, found %v", expected1, expected2, found)
 	}
 }
 
 func (td testDriver) TestCreateCustomer(t *testing.T) {
-	if err := td.api.createCustomer(customerName1); err != nil {
+	if _, err := td.api.createCustomer(customerName1); err != nil {
 		t.Fatalf("error creating customer: %v", err)
 	}
 	td.queryAndAssert(t, []string{customerName1},
 		fmt.Sprintf(`SELECT name FROM %s`, td.tableNames.customersTable))
 }
 func (td testDriver) TestCreateProduct(t *testing.T) {
-	if err := td.api.createProduct(productName1, productPrice1Float); err != nil {
+	if _, err := td.api.createProduct(productName1, productPrice1Float); err != nil {
 		t.Fatalf("error creating product: %v", err)
 	}
 	td.queryAndAssert(t, []string{row(productName1, productPrice1)},
 		fmt.Sprintf(`SELECT name, price FROM %s`, td.tableNames.productsTable))
 }
 
-func (td testDriver) TestCreateOrder(t *testing.T) {
-	// Get the single customer ID.
-	customerIDs, err := td.queryIDs(t, td.tableNames.customersTable)
+// TestCancelledRequestCancelsStatement checks that the application cancels
+// the statement of a request that the client abandons, instead of leaving it
+// running on the server.
+func (td testDriver) TestCancelledRequestCancelsStatement(t *testing.T) {
+	customerID := td.singleID(t, td.tableNames.customersTable)
+
+	// Lock the customer's row, so that the statement deleting it blocks.
+	tx, err := td.db.Begin()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(customerIDs) != 1 {
-		t.Fatalf("expected a single customer ID, found %v", customerIDs)
-	}
-	customerID := customerIDs[0]
-
-	// Get the single product.
-	productIDs, err := td.queryIDs(t, td.tableNames.productsTable)
-	if err != nil {
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 FOR UPDATE`,
+		td.tableNames.customersTable), customerID); err != nil {
 		t.Fatal(err)
 	}
-	if len(productIDs) != 1 {
-		t.Fatalf("expected a single product ID, found %v", productIDs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- td.api.deleteCustomer(ctx, customerID)
+	}()
+
+	pattern := fmt.Sprintf("DELETE FROM %%%s%%", td.tableNames.customersTable)
+	td.waitForRunningStatements(t, pattern, 1)
+	cancel()
+	if err := <-errCh; err == nil {
+		t.Fatal("expected cancelled request to fail")
 	}
-	productID := productIDs[0]
+	// The row is still locked, so the statement can only have stopped running
+	// by being cancelled.
+	td.waitForRunningStatements(t, pattern, 0)
+}
 
-	if err := td.api.createOrder(customerID, productID, productPrice1Float); err != nil {
+func (td testDriver) TestCreateOrder(t *testing.T) {
+	customerID := td.singleID(t, td.tableNames.customersTable)
+	productID := td.singleID(t, td.tableNames.productsTable)
+
+	if _, err := td.api.createOrder(customerID, productID, productPrice1Float); err != nil {
 		t.Fatalf("error creating order: %v", err)
 	}
 	td.queryAndAssert(t, []string{row(productPrice1)},
@@ -248,8 +285,8 @@ func (td testDriver) TestRetrieveCustomerAfterCreation(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	expected := []model.Customer{
-		{Name: &customerName1},
+	expected := []client.Customer{
+		{Name: customerName1},
 	}
 	if !reflect.DeepEqual(expected, cleanCustomers(found)) {
 		t.Fatalf("expecting customers from api after creation to be %v, found %v", expected, found)
@@ -261,24 +298,389 @@ func (td testDriver) TestRetrieveProductAfterCreation(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	expected := []model.Product{
-		{Name: &productName1, Price: productPrice1Float},
+	expected := []client.Product{
+		{Name: productName1, Price: productPrice1Float},
 	}
 	if !reflect.DeepEqual(expected, cleanProducts(found)) {
 		t.Fatalf("expecting products from api after creation to be %v, found %v", expected, found)
 	}
 }
+
+// TestRetrieveOrderAfterCreation checks that the API returns the order along
+// with its customer and products, regardless of their IDs. The relations that
+// the API does not return are reported, and fail the test for applications
+// that are expected to load them.
 func (td testDriver) TestRetrieveOrderAfterCreation(t *testing.T) {
 	found, err := td.api.queryOrders()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	expected := []model.Order{
-		{Subtotal: productPrice1Float},
+	shapes, missing := td.orderShapes(t, found)
+	if len(missing) > 0 {
+		if td.loadsOrderRelations {
+			t.Errorf("orders from api are missing relations %v", missing)
+		} else {
+			t.Logf("orders from api are missing relations %v", missing)
+		}
+	}
+	expected := []orderShape{
+		{Subtotal: productPrice1Float, Customer: customerName1, Products: []string{productName1}},
+	}
+	for i := range expected {
+		expected[i] = expected[i].withoutRelations(missing)
+	}
+	if !reflect.DeepEqual(expected, shapes) {
+		t.Fatalf("expecting orders from api after creation to be %+v, found %+v", expected, found)
+	}
+}
+
+func (td testDriver) TestRetrieveCustomerByID(t *testing.T) {
+	td.routes.skipUnlessServed(t, getCustomerRoute)
+	id := td.singleID(t, td.tableNames.customersTable)
+	found, err := td.api.getCustomer(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &client.Customer{ID: id, Name: customerName1}
+	if !reflect.DeepEqual(expected, found) {
+		t.Fatalf("expecting customer %d from api to be %v, found %v", id, expected, found)
+	}
+}
+func (td testDriver) TestRetrieveProductByID(t *testing.T) {
+	td.routes.skipUnlessServed(t, getProductRoute)
+	id := td.singleID(t, td.tableNames.productsTable)
+	found, err := td.api.getProduct(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &client.Product{ID: id, Name: productName1, Price: productPrice1Float}
+	if !reflect.DeepEqual(expected, found) {
+		t.Fatalf("expecting product %d from api to be %v, found %v", id, expected, found)
+	}
+}
+
+// TestRetrieveOrderByID checks the order that the API returns by its ID like
+// TestRetrieveOrderAfterCreation checks the list of orders.
+func (td testDriver) TestRetrieveOrderByID(t *testing.T) {
+	td.routes.skipUnlessServed(t, getOrderRoute)
+	id := td.singleID(t, td.tableNames.ordersTable)
+	found, err := td.api.getOrder(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != id {
+		t.Fatalf("expecting order %d from api, found %+v", id, found)
+	}
+
+	shapes, missing := td.orderShapes(t, []client.Order{*found})
+	if len(missing) > 0 {
+		if td.loadsOrderRelations {
+			t.Errorf("order from api is missing relations %v", missing)
+		} else {
+			t.Logf("order from api is missing relations %v", missing)
+		}
+	}
+	expected := orderShape{
+		Subtotal: productPrice1Float, Customer: customerName1, Products: []string{productName1},
+	}.withoutRelations(missing)
+	if !reflect.DeepEqual([]orderShape{expected}, shapes) {
+		t.Fatalf("expecting order %d from api to be %+v, found %+v", id, expected, found)
+	}
+}
+
+// expectNotFound sends the request of route for an object that does not
+// exist, unless the route is not expected to respond to it with 404 Not
+// Found, and checks that it does.
+func (td testDriver) expectNotFound(t *testing.T, route apiRoute, send func() error) {
+	t.Helper()
+	if reason := td.routes.missingIDReason(route); reason != "" {
+		t.Logf("not checking %s with a missing ID: %s", route, reason)
+		return
 	}
-	if !reflect.DeepEqual(expected, cleanOrders(found)) {
-		t.Fatalf("expecting orders from api after creation to be %v, found %v", expected, found)
+	expectStatus(t, string(route)+" with a missing ID", send(), http.StatusNotFound)
+}
+
+// TestMissingCustomer checks that the routes of a customer that does not
+// exist respond with 404 Not Found.
+func (td testDriver) TestMissingCustomer(t *testing.T) {
+	id := td.unusedID(t, td.tableNames.customersTable)
+
+	td.expectNotFound(t, getCustomerRoute, func() error {
+		_, err := td.api.getCustomer(id)
+		return err
+	})
+	td.expectNotFound(t, updateCustomerRoute, func() error {
+		_, err := td.api.updateCustomer(id, customerName2)
+		return err
+	})
+	td.expectNotFound(t, deleteCustomerRoute, func() error {
+		return td.api.deleteCustomer(context.Background(), id)
+	})
+	td.queryAndAssert(t, []string{"1"},
+		fmt.Sprintf(`SELECT count(*) FROM %s`, td.tableNames.customersTable))
+}
+func (td testDriver) TestMissingProduct(t *testing.T) {
+	id := td.unusedID(t, td.tableNames.productsTable)
+
+	td.expectNotFound(t, getProductRoute, func() error {
+		_, err := td.api.getProduct(id)
+		return err
+	})
+	td.expectNotFound(t, updateProductRoute, func() error {
+		_, err := td.api.updateProduct(id, productName2, productPrice2Float)
+		return err
+	})
+	td.expectNotFound(t, deleteProductRoute, func() error {
+		return td.api.deleteProduct(id)
+	})
+	td.queryAndAssert(t, []string{"1"},
+		fmt.Sprintf(`SELECT count(*) FROM %s`, td.tableNames.productsTable))
+}
+func (td testDriver) TestMissingOrder(t *testing.T) {
+	id := td.unusedID(t, td.tableNames.ordersTable)
+	customerID := td.singleID(t, td.tableNames.customersTable)
+	productID := td.singleID(t, td.tableNames.productsTable)
+
+	td.expectNotFound(t, getOrderRoute, func() error {
+		_, err := td.api.getOrder(id)
+		return err
+	})
+	td.expectNotFound(t, updateOrderRoute, func() error {
+		_, err := td.api.updateOrder(id, customerID, productPrice2Float, nil)
+		return err
+	})
+	td.expectNotFound(t, deleteOrderRoute, func() error {
+		return td.api.deleteOrder(id)
+	})
+	td.expectNotFound(t, addProductToOrderRoute, func() error {
+		_, err := td.api.addProductToOrder(id, productID)
+		return err
+	})
+	td.queryAndAssert(t, []string{"1"},
+		fmt.Sprintf(`SELECT count(*) FROM %s`, td.tableNames.ordersTable))
+	td.queryAndAssert(t, []string{"1"},
+		fmt.Sprintf(`SELECT count(*) FROM %s`, td.tableNames.orderProductsTable))
+}
+
+// TestMalformedIDs checks that the routes of every object respond with 400
+// Bad Request to IDs that are not integers.
+func (td testDriver) TestMalformedIDs(t *testing.T) {
+	orderID := td.singleID(t, td.tableNames.ordersTable)
+	productID := td.singleID(t, td.tableNames.productsTable)
+
+	for _, req := range []struct {
+		route        apiRoute
+		method, path string
+	}{
+		{getCustomerRoute, http.MethodGet, "/customer/abc/"},
+		{updateCustomerRoute, http.MethodPut, "/customer/abc/"},
+		{deleteCustomerRoute, http.MethodDelete, "/customer/abc/"},
+		{getProductRoute, http.MethodGet, "/product/abc/"},
+		{updateProductRoute, http.MethodPut, "/product/abc/"},
+		{deleteProductRoute, http.MethodDelete, "/product/abc/"},
+		{getOrderRoute, http.MethodGet, "/order/abc/"},
+		{updateOrderRoute, http.MethodPut, "/order/abc/"},
+		{deleteOrderRoute, http.MethodDelete, "/order/abc/"},
+		{addProductToOrderRoute, http.MethodPost, fmt.Sprintf("/order/abc/product/?productID=%d", productID)},
+		{addProductToOrderRoute, http.MethodPost, fmt.Sprintf("/order/%d/product/?productID=abc", orderID)},
+		{addProductToOrderRoute, http.MethodPost, fmt.Sprintf("/order/%d/product/", orderID)},
+	} {
+		if reason := td.routes.malformedIDReason(req.route); reason != "" {
+			t.Logf("not checking %s %s: %s", req.method, req.path, reason)
+			continue
+		}
+		status, err := td.api.requestStatus(req.method, req.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status %d, found %d", req.method, req.path, http.StatusBadRequest, status)
+		}
+	}
+}
+
+// TestUpdateCustomer renames the customer, and then restores its name.
+func (td testDriver) TestUpdateCustomer(t *testing.T) {
+	td.routes.skipUnlessServed(t, updateCustomerRoute)
+	id := td.singleID(t, td.tableNames.customersTable)
+	query := fmt.Sprintf(`SELECT name FROM %s WHERE id = $1`, td.tableNames.customersTable)
+
+	for _, name := range []string{customerName2, customerName1} {
+		found, err := td.api.updateCustomer(id, name)
+		if err != nil {
+			t.Fatalf("error updating customer: %v", err)
+		}
+		if expected := (&client.Customer{ID: id, Name: name}); !reflect.DeepEqual(expected, found) {
+			t.Fatalf("expecting updated customer to be %v, found %v", expected, found)
+		}
+		td.queryAndAssert(t, []string{name}, query, id)
+	}
+}
+
+// TestUpdateProduct renames and reprices the product, and then restores it.
+func (td testDriver) TestUpdateProduct(t *testing.T) {
+	td.routes.skipUnlessServed(t, updateProductRoute)
+	id := td.singleID(t, td.tableNames.productsTable)
+	query := fmt.Sprintf(`SELECT name, price FROM %s WHERE id = $1`, td.tableNames.productsTable)
+
+	for _, p := range []struct {
+		name, price string
+		priceFloat  float64
+	}{
+		{productName2, productPrice2, productPrice2Float},
+		{productName1, productPrice1, productPrice1Float},
+	} {
+		found, err := td.api.updateProduct(id, p.name, p.priceFloat)
+		if err != nil {
+			t.Fatalf("error updating product: %v", err)
+		}
+		if expected := (&client.Product{ID: id, Name: p.name, Price: p.priceFloat}); !reflect.DeepEqual(expected, found) {
+			t.Fatalf("expecting updated product to be %v, found %v", expected, found)
+		}
+		td.queryAndAssert(t, []string{row(p.name, p.price)}, query, id)
+	}
+}
+
+// TestUpdateOrder changes the subtotal of the order without giving its
+// products, which must be kept, and then restores it.
+func (td testDriver) TestUpdateOrder(t *testing.T) {
+	td.routes.skipUnlessServed(t, updateOrderRoute)
+	id := td.singleID(t, td.tableNames.ordersTable)
+	customerID := td.singleID(t, td.tableNames.customersTable)
+	productID := td.singleID(t, td.tableNames.productsTable)
+	query := fmt.Sprintf(`SELECT customer_id, subtotal FROM %s WHERE id = $1`, td.tableNames.ordersTable)
+
+	for _, subtotal := range []struct {
+		s string
+		f float64
+	}{
+		{productPrice2, productPrice2Float},
+		{productPrice1, productPrice1Float},
+	} {
+		found, err := td.api.updateOrder(id, customerID, subtotal.f, nil)
+		if err != nil {
+			t.Fatalf("error updating order: %v", err)
+		}
+		if found.ID != id || found.Subtotal != subtotal.f || found.Customer.ID != customerID {
+			t.Fatalf("expecting updated order %d of customer %d to have subtotal %v, found %+v",
+				id, customerID, subtotal.f, found)
+		}
+		td.queryAndAssert(t, []string{row(customerID, subtotal.s)}, query, id)
+		td.assertOrderProducts(t, id, productID)
+	}
+}
+
+// TestAddProductToOrder adds a second product to the order, and then removes
+// it again by replacing the products of the order.
+func (td testDriver) TestAddProductToOrder(t *testing.T) {
+	td.routes.skipUnlessServed(t, addProductToOrderRoute, updateOrderRoute, deleteProductRoute)
+	id := td.singleID(t, td.tableNames.ordersTable)
+	customerID := td.singleID(t, td.tableNames.customersTable)
+	productID := td.singleID(t, td.tableNames.productsTable)
+
+	product, err := td.api.createProduct(productName2, productPrice2Float)
+	if err != nil {
+		t.Fatalf("error creating product: %v", err)
+	}
+	found, err := td.api.addProductToOrder(id, product.ID)
+	if err != nil {
+		t.Fatalf("error adding product to order: %v", err)
+	}
+	if len(found.Products) != 2 {
+		t.Fatalf("expecting order to have 2 products after adding one, found %+v", found)
+	}
+	td.assertOrderProducts(t, id, productID, product.ID)
+
+	found, err = td.api.updateOrder(id, customerID, productPrice1Float, []int{productID})
+	if err != nil {
+		t.Fatalf("error updating order: %v", err)
+	}
+	if len(found.Products) != 1 || found.Products[0].ID != productID {
+		t.Fatalf("expecting order to have product %d after replacing its products, found %+v", productID, found)
+	}
+	td.assertOrderProducts(t, id, productID)
+
+	if err := td.api.deleteProduct(product.ID); err != nil {
+		t.Fatalf("error deleting product: %v", err)
+	}
+}
+
+// TestDeleteOrder creates an order for a new customer, and then deletes both.
+func (td testDriver) TestDeleteOrder(t *testing.T) {
+	td.routes.skipUnlessServed(t, deleteOrderRoute, deleteCustomerRoute)
+	productID := td.singleID(t, td.tableNames.productsTable)
+
+	customer, err := td.api.createCustomer(customerName2)
+	if err != nil {
+		t.Fatalf("error creating customer: %v", err)
+	}
+	order, err := td.api.createOrder(customer.ID, productID, productPrice1Float)
+	if err != nil {
+		t.Fatalf("error creating order: %v", err)
+	}
+	td.assertOrderProducts(t, order.ID, productID)
+
+	if err := td.api.deleteOrder(order.ID); err != nil {
+		t.Fatalf("error deleting order: %v", err)
+	}
+	td.queryAndAssert(t, []string{"0"},
+		fmt.Sprintf(`SELECT count(*) FROM %s WHERE id = $1`, td.tableNames.ordersTable), order.ID)
+	td.assertOrderProducts(t, order.ID)
+	td.expectNotFound(t, getOrderRoute, func() error {
+		_, err := td.api.getOrder(order.ID)
+		return err
+	})
+
+	if err := td.api.deleteCustomer(context.Background(), customer.ID); err != nil {
+		t.Fatalf("error deleting customer: %v", err)
+	}
+	td.queryAndAssert(t, []string{"0"},
+		fmt.Sprintf(`SELECT count(*) FROM %s WHERE id = $1`, td.tableNames.customersTable), customer.ID)
+	td.expectNotFound(t, getCustomerRoute, func() error {
+		_, err := td.api.getCustomer(customer.ID)
+		return err
+	})
+}
+
+// TestDeleteProduct creates a product, and then deletes it.
+func (td testDriver) TestDeleteProduct(t *testing.T) {
+	td.routes.skipUnlessServed(t, deleteProductRoute)
+	product, err := td.api.createProduct(productName2, productPrice2Float)
+	if err != nil {
+		t.Fatalf("error creating product: %v", err)
+	}
+	query := fmt.Sprintf(`SELECT count(*) FROM %s WHERE id = $1`, td.tableNames.productsTable)
+	td.queryAndAssert(t, []string{"1"}, query, product.ID)
+
+	if err := td.api.deleteProduct(product.ID); err != nil {
+		t.Fatalf("error deleting product: %v", err)
+	}
+	td.queryAndAssert(t, []string{"0"}, query, product.ID)
+	td.expectNotFound(t, getProductRoute, func() error {
+		_, err := td.api.getProduct(product.ID)
+		return err
+	})
+}
+
+// TestResponsesMatchContract checks that every response the application
+// returned so far conforms to the OpenAPI document of the REST API, and that
+// the application serves that document if it serves one at all.
+func (td testDriver) TestResponsesMatchContract(t *testing.T) {
+	for _, violation := range apiContract.takeViolations(td.api.addr()) {
+		t.Error(violation)
+	}
+
+	spec, err := td.api.openAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec == nil {
+		t.Logf("application does not serve an OpenAPI document")
+	} else if !bytes.Equal(spec, api.OpenAPISpec()) {
+		t.Errorf("application serves an OpenAPI document that differs from the contract")
 	}
 }
 
@@ -302,6 +704,91 @@ func (td testDriver) queryIDs(t *testing.T, table string) ([]int, error) {
 	return ids, nil
 }
 
+// singleID returns the ID of the single row of table.
+func (td testDriver) singleID(t *testing.T, table string) int {
+	ids, err := td.queryIDs(t, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 {
+		t.Fatalf("expected a single ID in %s, found %v", table, ids)
+	}
+	return ids[0]
+}
+
+// unusedID returns an ID that no row of table has.
+func (td testDriver) unusedID(t *testing.T, table string) int {
+	var id int
+	if err := td.db.QueryRow(
+		fmt.Sprintf(`SELECT COALESCE(max(id), 0) + 1 FROM %s`, table),
+	).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// assertOrderProducts asserts that the rows of the order products table for
+// the order with the given ID refer to exactly the given products.
+func (td testDriver) assertOrderProducts(t *testing.T, orderID int, productIDs ...int) {
+	expected := append([]int(nil), productIDs...)
+	sort.Ints(expected)
+	if found := td.orderProductIDs(t, orderID); fmt.Sprint(expected) != fmt.Sprint(found) {
+		t.Fatalf("expecting products of order %d to be %v, found %v", orderID, expected, found)
+	}
+}
+
+// orderProductIDs returns the sorted IDs of the products that the rows of the
+// order products table for the order with the given ID refer to.
+func (td testDriver) orderProductIDs(t *testing.T, orderID int) []int {
+	// The columns referring to orders and products are the last two columns
+	// of the table, whatever they are called.
+	cols := td.columnNames.ordersProductsColumns
+	orderCol, productCol := cols[len(cols)-2], cols[len(cols)-1]
+
+	rows, err := td.db.Query(fmt.Sprintf(`SELECT %s FROM %s WHERE %s = $1 ORDER BY 1`,
+		productCol, td.tableNames.orderProductsTable, orderCol), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// waitForRunningStatements waits until exactly n statements matching the
+// LIKE pattern are running on the cluster.
+func (td testDriver) waitForRunningStatements(t *testing.T, pattern string, n int) {
+	const maxWait = 30 * time.Second
+	const waitDelay = 100 * time.Millisecond
+
+	for waited := time.Duration(0); ; waited += waitDelay {
+		var found int
+		if err := td.db.QueryRow(
+			`SELECT count(*) FROM crdb_internal.cluster_queries WHERE query LIKE $1`, pattern,
+		).Scan(&found); err != nil {
+			t.Fatal(err)
+		}
+		if found == n {
+			return
+		}
+		if waited > maxWait {
+			t.Fatalf("expected %d running statements matching %q, found %d", n, pattern, found)
+		}
+		time.Sleep(waitDelay)
+	}
+}
+
 func (td testDriver) query(t *testing.T, query string, args ...interface{}) []string {
 	rows, err := td.db.Query(query, args...)
 	if err != nil {
@@ -359,3 +846,10 @@ func row(vals ...interface{}) string {
 	}
 	return b.String()
 }
+
+func expectStatus(t *testing.T, op string, err error, code int) {
+	t.Helper()
+	if found := client.StatusCode(err); found != code {
+		t.Errorf("%s: expected status %d, found %d (%v)", op, code, found, err)
+	}
+}